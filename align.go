@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// minAlignEdgeMm is the shortest XY edge alignToEdge accepts; closer points
+// don't define a direction.
+const minAlignEdgeMm = 0.01
+
+// alignment is an "align to edge" frame in the XY plane: the part's origin
+// and the angle of its edge from machine +X. In the aligned frame the edge
+// is the X axis. Z is untouched.
+type alignment struct {
+	OriginX float64 `json:"originX"` // mm, machine frame
+	OriginY float64 `json:"originY"` // mm, machine frame
+	Angle   float64 `json:"angle"`   // radians, counterclockwise from machine +X
+}
+
+// apply moves p from machine coordinates into the aligned frame.
+func (a alignment) apply(p point) point {
+	dx, dy := p.x-a.OriginX, p.y-a.OriginY
+	sin, cos := math.Sincos(a.Angle)
+	p.x, p.y = dx*cos+dy*sin, -dx*sin+dy*cos
+	return p
+}
+
+// alignToEdge sets the active session's alignment from two of its points:
+// a becomes the origin and the edge from a to b the X axis. -1 for both
+// uses the last two points.
+func alignToEdge(a, b int) (alignment, error) {
+	al, err := setAlignment(a, b)
+	if err == nil {
+		notePointsChanged() // autosave keeps the alignment with the session
+	}
+	return al, err
+}
+
+func setAlignment(a, b int) (alignment, error) {
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	n := len(active.points)
+	if a == -1 && b == -1 {
+		a, b = n-2, n-1
+	}
+	switch {
+	case n < 2:
+		return alignment{}, fmt.Errorf("need at least two points, have %d", n)
+	case a < 0 || a >= n || b < 0 || b >= n:
+		return alignment{}, fmt.Errorf("points %d and %d out of range (0..%d)", a, b, n-1)
+	}
+	pa, pb := active.points[a], active.points[b]
+	dx, dy := pb.x-pa.x, pb.y-pa.y
+	if math.Hypot(dx, dy) < minAlignEdgeMm {
+		return alignment{}, fmt.Errorf("points %d and %d are less than %v mm apart in XY", a, b, minAlignEdgeMm)
+	}
+	al := alignment{OriginX: pa.x, OriginY: pa.y, Angle: math.Atan2(dy, dx)}
+	active.align = &al
+	return al, nil
+}
+
+// currentAlignment returns the active session's alignment, if it has one.
+func currentAlignment() (alignment, bool) {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	if active.align == nil {
+		return alignment{}, false
+	}
+	return *active.align, true
+}
+
+func clearAlignment() {
+	pointsMu.Lock()
+	active.align = nil
+	pointsMu.Unlock()
+	notePointsChanged()
+}
+
+// snapshotAlignedPoints is snapshotPoints in the active session's aligned
+// frame, with the alignment used; al is nil, and the points are in machine
+// coordinates, when the session isn't aligned.
+func snapshotAlignedPoints() (pts []point, al *alignment) {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	pts = make([]point, len(active.points))
+	copy(pts, active.points)
+	if active.align == nil {
+		return pts, nil
+	}
+	a := *active.align
+	for i, p := range pts {
+		pts[i] = a.apply(p)
+	}
+	return pts, &a
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// archiveSchema is the current session archive schema. Bump it when the
+// format changes and teach migrateArchive to upgrade older files.
+const archiveSchema = 1
+
+// sessionArchive is /api/session/export.json: the active session's points
+// plus everything needed to reproduce them on another device. Lengths are
+// in mm.
+type sessionArchive struct {
+	Schema   int           `json:"schema"`
+	Version  string        `json:"version"` // closinuf build that wrote it
+	Exported time.Time     `json:"exported"`
+	Mode     string        `json:"mode"` // modeAbs or modeInc ("abs" or "inc") when exported
+	Unit     string        `json:"unit"` // the session's display unit
+	Session  sessionFile   `json:"session"`
+	Axes     []archiveAxis `json:"axes"`
+}
+
+// archiveAxis is one axis's scaling and datum. Offsets are hardware counts,
+// so they only mean something on the same device before a power cycle.
+type archiveAxis struct {
+	Label           string  `json:"label"`
+	CountsPerRev    float64 `json:"countsPerRev"`
+	WheelDiameterMm float64 `json:"wheelDiameterMm"`
+	Calibration     float64 `json:"calibration"`
+	BacklashCounts  int     `json:"backlashCounts"`
+	SwapAB          bool    `json:"swapAB"`
+	OffsetCounts    int64   `json:"offsetCounts"`
+	IncOffsetCounts int64   `json:"incOffsetCounts"`
+}
+
+// archiveResult reports what an archive import restored.
+type archiveResult struct {
+	Session sessionInfo `json:"session"`
+	Axes    []string    `json:"axes"`              // axes whose settings were applied
+	Skipped []string    `json:"skipped,omitempty"` // archive axes this device doesn't have
+}
+
+// snapshotArchive captures the active session and every enabled axis.
+func snapshotArchive() sessionArchive {
+	a := sessionArchive{
+		Schema:   archiveSchema,
+		Version:  version,
+		Exported: time.Now(),
+		Mode:     coordMode(),
+	}
+	pointsMu.RLock()
+	a.Unit = active.unit
+	a.Session = sessionFile{Name: active.name, Created: active.created, Unit: active.unit, Points: make([]pointJSON, len(active.points)), Align: active.align}
+	for i, p := range active.points {
+		a.Session.Points[i] = pointJSON{Index: i, X: p.x, Y: p.y, Z: p.z, Source: p.source, CapturedAt: p.capturedAt, Label: p.label, ProbeRadiusMm: p.probeRadius}
+	}
+	pointsMu.RUnlock()
+	for _, enc := range enabledEncoders() {
+		enc.mu.RLock()
+		a.Axes = append(a.Axes, archiveAxis{
+			Label:           enc.label,
+			CountsPerRev:    enc.countsPerRev,
+			WheelDiameterMm: enc.circumference / math.Pi,
+			Calibration:     enc.calibration,
+			BacklashCounts:  enc.backlash,
+			SwapAB:          enc.swapAB,
+			OffsetCounts:    enc.offset,
+			IncOffsetCounts: enc.incOffset,
+		})
+		enc.mu.RUnlock()
+	}
+	return a
+}
+
+// readArchive parses and validates an archive, migrating older schemas.
+func readArchive(r io.Reader) (sessionArchive, error) {
+	var a sessionArchive
+	if err := json.NewDecoder(r).Decode(&a); err != nil {
+		return a, fmt.Errorf("parse archive: %w", err)
+	}
+	if err := migrateArchive(&a); err != nil {
+		return a, err
+	}
+	if a.Mode != "" && a.Mode != modeAbs && a.Mode != modeInc {
+		return a, fmt.Errorf("mode: got %q, want %q or %q", a.Mode, modeAbs, modeInc)
+	}
+	name, err := validateSessionName(a.Session.Name)
+	if err != nil {
+		return a, err
+	}
+	a.Session.Name = name
+	if _, err := mmPerUnit(a.Session.Unit); err != nil {
+		return a, err
+	}
+	for i, p := range a.Session.Points {
+		if !finite(p.X) || !finite(p.Y) || !finite(p.Z) {
+			return a, fmt.Errorf("point %d: coordinates must be finite numbers", i)
+		}
+		if _, err := validatePointLabel(p.Label); err != nil {
+			return a, fmt.Errorf("point %d: %w", i, err)
+		}
+	}
+	if al := a.Session.Align; al != nil && (!finite(al.OriginX) || !finite(al.OriginY) || !finite(al.Angle)) {
+		return a, fmt.Errorf("alignment must be finite numbers")
+	}
+	for _, ax := range a.Axes {
+		switch {
+		case !(ax.CountsPerRev > 0) || !(ax.WheelDiameterMm > 0):
+			return a, fmt.Errorf("axis %s: countsPerRev and wheelDiameterMm must be positive", ax.Label)
+		case ax.Calibration != 0 && !validCalibration(ax.Calibration):
+			return a, fmt.Errorf("axis %s: calibration %v out of range %v..%v", ax.Label, ax.Calibration, minCalibration, maxCalibration)
+		case ax.BacklashCounts < 0 || ax.BacklashCounts > maxBacklashCounts:
+			return a, fmt.Errorf("axis %s: backlashCounts %d out of range 0..%d", ax.Label, ax.BacklashCounts, maxBacklashCounts)
+		}
+	}
+	return a, nil
+}
+
+// migrateArchive upgrades an archive to archiveSchema in place. There is
+// only one schema so far; later ones add a case per step.
+func migrateArchive(a *sessionArchive) error {
+	switch {
+	case a.Schema == archiveSchema:
+		return nil
+	case a.Schema > archiveSchema:
+		return fmt.Errorf("archive schema %d is newer than this build's %d; upgrade closinuf", a.Schema, archiveSchema)
+	}
+	return fmt.Errorf("archive schema %d not supported", a.Schema)
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// restoreArchive applies an archive's axis settings (saved to the config
+// file), restores its datums when offsets is set, and replaces or creates
+// its session and makes it active.
+func restoreArchive(a sessionArchive, offsets bool) (archiveResult, error) {
+	var res archiveResult
+	for _, ax := range a.Axes {
+		enc, ok := encoderByAxis(ax.Label)
+		if !ok {
+			res.Skipped = append(res.Skipped, ax.Label)
+			continue
+		}
+		if err := enc.setScale(ax.CountsPerRev, ax.WheelDiameterMm); err != nil {
+			return res, err
+		}
+		factor := ax.Calibration
+		if factor == 0 {
+			factor = 1
+		}
+		if err := enc.calibrate(0, 0, factor); err != nil {
+			return res, err
+		}
+		if err := enc.setBacklash(ax.BacklashCounts); err != nil {
+			return res, err
+		}
+		if err := enc.setSwapAB(ax.SwapAB); err != nil {
+			return res, err
+		}
+		if offsets {
+			enc.restoreDatum(ax.OffsetCounts, ax.IncOffsetCounts)
+		}
+		res.Axes = append(res.Axes, enc.label)
+	}
+	if len(res.Skipped) > 0 {
+		slog.Warn("archive axes not on this device", "axes", res.Skipped)
+	}
+
+	s := &session{name: a.Session.Name, created: a.Session.Created, unit: a.Session.Unit, points: make([]point, len(a.Session.Points)), align: a.Session.Align}
+	for i, p := range a.Session.Points {
+		s.points[i] = point{x: p.X, y: p.Y, z: p.Z, source: p.Source, capturedAt: p.CapturedAt, label: p.Label, probeRadius: p.ProbeRadiusMm}
+	}
+	if s.created.IsZero() {
+		s.created = time.Now()
+	}
+	pointsMu.Lock()
+	sessions[s.name] = s
+	active = s
+	res.Session = s.info()
+	pointsMu.Unlock()
+	notePointsChanged()
+	return res, nil
+}
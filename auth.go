@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// authSettings is the optional access control, read from the environment
+// so secrets stay out of the config file (which the API rewrites):
+//
+//	CLOSINUF_TOKEN       bearer token for scripts (Authorization: Bearer ...)
+//	CLOSINUF_BASIC_AUTH  user:password for HTTP basic auth; browsers prompt
+//	CLOSINUF_AUTH_READS  1 to protect reads too, not only changes
+//
+// With no token or password set, every route is open.
+type authSettings struct {
+	token      string
+	user, pass string
+	reads      bool
+}
+
+// openRoutes stay open even for changes: they only set the caller's own
+// display cookies, or are what a watchdog polls.
+var openRoutes = map[string]bool{
+	"/api/theme/toggle": true,
+	"/api/ui/refresh":   true,
+	"/healthz":          true,
+}
+
+func authFromEnv() (authSettings, error) {
+	a := authSettings{
+		token: os.Getenv("CLOSINUF_TOKEN"),
+		reads: os.Getenv("CLOSINUF_AUTH_READS") == "1",
+	}
+	if basic := os.Getenv("CLOSINUF_BASIC_AUTH"); basic != "" {
+		var ok bool
+		a.user, a.pass, ok = strings.Cut(basic, ":")
+		if !ok || a.pass == "" {
+			return a, fmt.Errorf("CLOSINUF_BASIC_AUTH: want user:password")
+		}
+	}
+	if a.reads && !a.enabled() {
+		return a, fmt.Errorf("CLOSINUF_AUTH_READS needs CLOSINUF_TOKEN or CLOSINUF_BASIC_AUTH")
+	}
+	return a, nil
+}
+
+func (a authSettings) enabled() bool {
+	return a.token != "" || a.pass != ""
+}
+
+// protects reports whether a request needs credentials: anything that can
+// change state, and reads too when reads is set.
+func (a authSettings) protects(c *fiber.Ctx) bool {
+	if openRoutes[c.Path()] {
+		return false
+	}
+	switch c.Method() {
+	case fiber.MethodOptions:
+		return false
+	case fiber.MethodGet, fiber.MethodHead:
+		return a.reads
+	}
+	return true
+}
+
+// allowed checks the request's Authorization header in constant time.
+func (a authSettings) allowed(c *fiber.Ctx) bool {
+	scheme, cred, _ := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	switch {
+	case strings.EqualFold(scheme, "Bearer") && a.token != "":
+		return secretEqual(cred, a.token)
+	case strings.EqualFold(scheme, "Basic") && a.pass != "":
+		raw, err := base64.StdEncoding.DecodeString(cred)
+		if err != nil {
+			return false
+		}
+		user, pass, _ := strings.Cut(string(raw), ":")
+		return secretEqual(user, a.user) && secretEqual(pass, a.pass)
+	}
+	return false
+}
+
+func secretEqual(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// requireAuth is middleware that answers 401 to protected requests without
+// valid credentials. It is a no-op when auth isn't configured.
+func requireAuth(a authSettings) fiber.Handler {
+	if !a.enabled() {
+		return func(c *fiber.Ctx) error { return c.Next() }
+	}
+	slog.Info("auth enabled", "token", a.token != "", "basic", a.pass != "", "reads", a.reads)
+	return func(c *fiber.Ctx) error {
+		if !a.protects(c) || a.allowed(c) {
+			return c.Next()
+		}
+		if a.pass != "" {
+			c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="closinuf"`)
+		} else {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer realm="closinuf"`)
+		}
+		return c.Status(fiber.StatusUnauthorized).SendString("unauthorized")
+	}
+}
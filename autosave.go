@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// autosaveEvery is the minimum spacing between autosave writes.
+const autosaveEvery = time.Second
+
+// travelSaveStepMm is how far an axis's odometer may run before autosave
+// writes it again.
+const travelSaveStepMm = 100.0
+
+// pointsChanged wakes the autosave writer; one pending signal is enough.
+var pointsChanged = make(chan struct{}, 1)
+
+// notePointsChanged tells the autosave writer (if running) that points, or
+// an odometer, changed.
+func notePointsChanged() {
+	select {
+	case pointsChanged <- struct{}{}:
+	default:
+	}
+}
+
+// sessionsFile is the autosave format: every session, with points in mm,
+// and each axis's odometer.
+type sessionsFile struct {
+	Active   string             `json:"active"`
+	Sessions []sessionFile      `json:"sessions"`
+	Travel   map[string]float64 `json:"travelMm,omitempty"` // totalTravel by axis label
+}
+
+// savedTravel is the odometers loadAutosave found, for initEncoders.
+var savedTravel map[string]float64
+
+type sessionFile struct {
+	Name    string      `json:"name"`
+	Created time.Time   `json:"created"`
+	Unit    string      `json:"unit"`
+	Points  []pointJSON `json:"points"`
+	Align   *alignment  `json:"align,omitempty"`
+}
+
+// initAutosave restores sessions saved by a previous run and starts the
+// writer. It does nothing unless autosavePath is configured.
+func initAutosave(ctx context.Context) error {
+	path := cfg().AutosavePath
+	if path == "" {
+		return nil
+	}
+	n, err := loadAutosave(path)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("restored sessions", "count", n, "path", path)
+	}
+	startWorker(func() { autosaveForever(ctx, path) })
+	return nil
+}
+
+func loadAutosave(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read autosave: %w", err)
+	}
+	var f sessionsFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return 0, fmt.Errorf("parse autosave %s: %w", path, err)
+	}
+	savedTravel = f.Travel
+	restored := map[string]*session{}
+	for _, sf := range f.Sessions {
+		if _, err := validateSessionName(sf.Name); err != nil {
+			return 0, fmt.Errorf("autosave %s: %w", path, err)
+		}
+		s := &session{name: sf.Name, created: sf.Created, unit: sf.Unit, points: make([]point, len(sf.Points)), align: sf.Align}
+		for i, p := range sf.Points {
+			s.points[i] = point{x: p.X, y: p.Y, z: p.Z, source: p.Source, capturedAt: p.CapturedAt, label: p.Label, probeRadius: p.ProbeRadiusMm}
+		}
+		restored[s.name] = s
+	}
+	if len(restored) == 0 {
+		return 0, nil
+	}
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	sessions = restored
+	active = sessions[f.Active]
+	if active == nil {
+		active = sessions[f.Sessions[0].Name]
+	}
+	return len(restored), nil
+}
+
+// snapshotSessions copies every session for writing, under pointsMu, and
+// the axes' odometers.
+func snapshotSessions() sessionsFile {
+	f := sessionsFile{Travel: map[string]float64{}}
+	for _, enc := range enabledEncoders() {
+		enc.mu.RLock()
+		f.Travel[enc.label] = enc.totalTravel
+		enc.mu.RUnlock()
+	}
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	f.Active = active.name
+	for _, info := range listSessionsLocked() {
+		s := sessions[info.Name]
+		sf := sessionFile{Name: s.name, Created: s.created, Unit: s.unit, Points: make([]pointJSON, len(s.points)), Align: s.align}
+		for i, p := range s.points {
+			sf.Points[i] = pointJSON{Index: i, X: p.x, Y: p.y, Z: p.z, Source: p.source, CapturedAt: p.capturedAt, Label: p.label, ProbeRadiusMm: p.probeRadius}
+		}
+		f.Sessions = append(f.Sessions, sf)
+	}
+	return f
+}
+
+// autosaveForever rewrites the autosave file after points or sessions change,
+// or an odometer runs travelSaveStepMm, at most once per autosaveEvery. When
+// ctx is cancelled it writes once more and returns.
+func autosaveForever(ctx context.Context, path string) {
+	for {
+		select {
+		case <-pointsChanged:
+			saveAutosave(path)
+		case <-ctx.Done():
+			saveAutosave(path)
+			return
+		}
+		select {
+		case <-time.After(autosaveEvery):
+		case <-ctx.Done():
+		}
+	}
+}
+
+func saveAutosave(path string) {
+	if err := writeAutosave(path); err != nil {
+		slog.Error("autosave", "err", err)
+	}
+}
+
+// writeAutosave replaces path atomically so a crash mid-write keeps the old file.
+func writeAutosave(path string) error {
+	data, err := json.MarshalIndent(snapshotSessions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
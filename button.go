@@ -1,58 +1,64 @@
+//go:build linux
+
 package main
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/warthog618/go-gpiocdev"
 )
 
-const (
-	pointButtonChip   = "gpiochip0"
-	pointButtonOffset = 26 // GPIO26 — NO foot switch (falling edge = press)
-	buttonDebounce    = 500 * time.Millisecond
-)
-
-var (
-	btnEventMu      sync.Mutex
-	btnPressHandled bool
-)
+var btnLine *gpiocdev.Line
 
-// initPointButton wires GPIO26 for physical capture.
+// initPointButton wires the foot switch (GPIO26 by default) for physical
+// capture. NO switch, by default to GND with a pull-up (LOW = pressed); with
+// buttonActiveHigh, to 3.3 V with a pull-down (HIGH = pressed).
 func initPointButton() error {
-	_, err := gpiocdev.RequestLines(pointButtonChip,
-		[]int{pointButtonOffset},
+	bias := gpiocdev.WithPullUp
+	if cfg().ButtonActiveHigh {
+		bias = gpiocdev.WithPullDown
+	}
+	btnEventMu.Lock()
+	resetPointButton()
+	btnEventMu.Unlock()
+
+	line, err := gpiocdev.RequestLine(cfg().Pins.Chip, cfg().Pins.PointButton,
 		gpiocdev.AsInput,
+		bias,
 		gpiocdev.WithEventHandler(onPointButtonEvent),
 		gpiocdev.WithBothEdges,
 		gpiocdev.WithConsumer("point-button"),
 	)
 	if err != nil {
-		return fmt.Errorf("point button GPIO%d: %w", pointButtonOffset, err)
+		return fmt.Errorf("point button GPIO%d: %w", cfg().Pins.PointButton, err)
 	}
+	btnEventMu.Lock()
+	btnLine = line
+	btnRead = line.Value
+	btnEventMu.Unlock()
 	return nil
 }
 
-func onPointButtonEvent(evt gpiocdev.LineEvent) {
+// closePointButton releases the foot-switch line on shutdown so a restarted
+// instance can request it at once. Close waits for the event handler, which
+// takes btnEventMu, so the line is closed outside the lock.
+func closePointButton() {
 	btnEventMu.Lock()
-	defer btnEventMu.Unlock()
-
-	// External pull-up: HIGH idle, LOW when pressed (NO).
-	if evt.Type == gpiocdev.LineEventFallingEdge {
-		if btnPressHandled {
-			return
-		}
-		if !captureAllowedSince(buttonDebounce) {
-			return
-		}
-		btnPressHandled = true
-		addCapturePoint()
-		playBeep()
-		return
+	line := btnLine
+	btnLine, btnRead = nil, nil
+	stopPointButton()
+	btnEventMu.Unlock()
+	if line != nil {
+		line.Close()
 	}
+}
 
-	if evt.Type == gpiocdev.LineEventRisingEdge {
-		btnPressHandled = false
+func onPointButtonEvent(evt gpiocdev.LineEvent) {
+	level := 1
+	if evt.Type == gpiocdev.LineEventFallingEdge {
+		level = 0
 	}
+	recordEvent(cfg().Pins.PointButton, level, time.Now())
+	onPointButtonLevel(level)
 }
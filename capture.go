@@ -2,61 +2,395 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
+	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+)
+
+// Point sources recorded with each capture.
+const (
+	sourceEncoder = "encoder" // live encoder position (web button or foot switch)
+	sourceManual  = "manual"  // coordinates entered by hand
+	sourceAverage = "average" // mean of samples taken while the foot switch was held
+	sourceImport  = "import"  // loaded from an uploaded ASC/CSV file
 )
 
 type point struct {
-	x float64
-	y float64
-	z float64
+	x           float64
+	y           float64
+	z           float64
+	source      string
+	capturedAt  time.Time
+	label       string  // optional name, e.g. "hole A"
+	probeRadius float64 // mm the ball tip's radius moved the point to the surface (0 = none)
+}
+
+// pointJSON is a captured point as listed by /api/points.
+type pointJSON struct {
+	Index         int       `json:"index"` // position in capture order, for deleting or editing
+	X             float64   `json:"x"`
+	Y             float64   `json:"y"`
+	Z             float64   `json:"z"`
+	Source        string    `json:"source"`
+	CapturedAt    time.Time `json:"capturedAt"`
+	Label         string    `json:"label,omitempty"`
+	ProbeRadiusMm float64   `json:"probeRadiusMm,omitempty"` // ball radius the point was compensated by
+}
+
+const defaultCaptureCooldown = 500 * time.Millisecond
+
+// maxPointLabel caps a point label's length in bytes.
+const maxPointLabel = 64
+
+// validatePointLabel trims label and rejects one that is too long or has
+// control characters such as newlines, which would break the text exports.
+func validatePointLabel(label string) (string, error) {
+	label = strings.TrimSpace(label)
+	if len(label) > maxPointLabel {
+		return "", fmt.Errorf("label longer than %d bytes", maxPointLabel)
+	}
+	if strings.ContainsFunc(label, unicode.IsControl) {
+		return "", fmt.Errorf("label %q contains control characters", label)
+	}
+	return label, nil
 }
 
 var (
-	points             []point
-	pointsMu           sync.RWMutex
-	lastPointAddedTime time.Time
+	pointsMu           sync.RWMutex // guards sessions and active (session.go)
+	lastPointAddedTime atomic.Int64 // UnixNano of the last capture, from the foot switch or web
+	captureCooldown    atomic.Int64 // time.Duration shared by foot switch and web capture
+)
+
+// initCaptureCooldown applies the configured cooldown, if any.
+func initCaptureCooldown() {
+	d := defaultCaptureCooldown
+	if cfg().CaptureCooldownMs != 0 {
+		d = time.Duration(cfg().CaptureCooldownMs) * time.Millisecond
+	}
+	captureCooldown.Store(int64(d))
+}
+
+func getCaptureCooldown() time.Duration {
+	return time.Duration(captureCooldown.Load())
+}
+
+// setCaptureCooldown validates d, applies it, and persists it to the config file.
+func setCaptureCooldown(d time.Duration) error {
+	if err := validateCaptureCooldown(d); err != nil {
+		return err
+	}
+	captureCooldown.Store(int64(d))
+	return updateConfig(func(c *config) { c.CaptureCooldownMs = int(d / time.Millisecond) })
+}
+
+// livePoint returns the current X, Y, Z encoder position as a point; a
+// missing or disabled axis reads 0. Points are always absolute (from the
+// datum), even while the display is in INC mode. While hold is on it is the
+// held position, unless holdCapture is "live".
+func livePoint() point {
+	live := holdMode.Load() && cfg().captureLiveWhileHeld()
+	return point{
+		x:      axisMM("X", live),
+		y:      axisMM("Y", live),
+		z:      axisMM("Z", live),
+		source: sourceEncoder,
+	}
+}
+
+// axisMM is the absolute position in mm of the enabled axis labelled label,
+// or 0 if there is none. With live it ignores hold.
+func axisMM(label string, live bool) float64 {
+	if enc, ok := encoderByAxis(label); ok {
+		if live {
+			return enc.liveMM()
+		}
+		return enc.absoluteMM()
+	}
+	return 0
+}
+
+// setOriginFromPoint makes the active session's point i (-1 for the last)
+// the origin: the X, Y, and Z datums shift by its coordinates, and X' by its
+// X to stay paired with X. With rebase the session's points move by the same
+// shift, so they stay where they were relative to the new readings, as does
+// any alignment. It
+// returns the point and the shift made, in mm.
+func setOriginFromPoint(i int, rebase bool) (origin, shift point, err error) {
+	pointsMu.RLock()
+	n := len(active.points)
+	if i == -1 {
+		i = n - 1
+	}
+	if i >= 0 && i < n {
+		origin = active.points[i]
+	}
+	pointsMu.RUnlock()
+	switch {
+	case n == 0:
+		return origin, shift, fmt.Errorf("no points captured")
+	case i < 0 || i >= n:
+		return origin, shift, fmt.Errorf("point %d out of range (0..%d)", i, n-1)
+	}
+	for _, a := range []struct {
+		label string
+		mm    float64
+		shift *float64
+	}{
+		{"X", origin.x, &shift.x},
+		{"X'", origin.x, nil},
+		{"Y", origin.y, &shift.y},
+		{"Z", origin.z, &shift.z},
+	} {
+		enc, ok := encoderByAxis(a.label)
+		if !ok {
+			continue
+		}
+		if moved := enc.shiftDatum(a.mm); a.shift != nil {
+			*a.shift = moved
+		}
+	}
+	if rebase {
+		pointsMu.Lock()
+		for j := range active.points {
+			p := &active.points[j]
+			p.x, p.y, p.z = p.x-shift.x, p.y-shift.y, p.z-shift.z
+		}
+		if active.align != nil {
+			al := *active.align
+			al.OriginX, al.OriginY = al.OriginX-shift.x, al.OriginY-shift.y
+			active.align = &al
+		}
+		pointsMu.Unlock()
+		notePointsChanged()
+	}
+	return origin, shift, nil
+}
+
+// tooCloseError rejects a capture within captureToleranceMm of the last point.
+type tooCloseError struct {
+	distance  float64 // mm from the last point
+	tolerance float64 // mm
+}
+
+func (e *tooCloseError) Error() string {
+	return fmt.Sprintf("point is %.3f mm from the last one (tolerance %.3f mm)", e.distance, e.tolerance)
+}
+
+// addCapturePoint captures the live position; see addCapturedPoint.
+func addCapturePoint(label string) error {
+	p := livePoint()
+	p.label = label
+	_, err := addCapturedPoint(p)
+	return err
+}
+
+// addCapturedPoint appends a captured point, compensated for the probe
+// radius, unless it lies within the capture tolerance of the active
+// session's last point. It returns the point stored.
+func addCapturedPoint(p point) (point, error) {
+	p = compensateProbe(p, probePath.snapshot())
+	if tol := cfg().CaptureToleranceMm; tol > 0 {
+		pointsMu.RLock()
+		n := len(active.points)
+		var d float64
+		if n > 0 {
+			d = math.Sqrt(dist2(active.points[n-1], p))
+		}
+		pointsMu.RUnlock()
+		if n > 0 && d < tol {
+			return p, &tooCloseError{distance: d, tolerance: tol}
+		}
+	}
+	addPoint(p)
+	return p, nil
+}
+
+// minApproachMm is how far back along the path trace the probe must have
+// come from for its approach direction to count.
+const minApproachMm = 0.5
+
+// compensateProbe moves a ball tip's center p out to the surface it
+// touched: probeRadiusMm further along the approach, the direction from the
+// latest path sample at least minApproachMm away. Without such a sample the
+// point is left as it is.
+func compensateProbe(p point, trace []point) point {
+	r := cfg().ProbeRadiusMm
+	if r == 0 {
+		return p
+	}
+	for i := len(trace) - 1; i >= 0; i-- {
+		d := math.Sqrt(dist2(p, trace[i]))
+		if d < minApproachMm {
+			continue
+		}
+		p.x += r * (p.x - trace[i].x) / d
+		p.y += r * (p.y - trace[i].y) / d
+		p.z += r * (p.z - trace[i].z) / d
+		p.probeRadius = r
+		return p
+	}
+	slog.Warn("no approach direction in the path trace, probe radius not compensated", "probeRadiusMm", r)
+	return p
+}
+
+// holdSampleEvery spaces the samples of an averaged capture, from the foot
+// switch or the web.
+const holdSampleEvery = 50 * time.Millisecond
+
+// Sample counts for an averaged capture; samples are holdSampleEvery apart.
+const (
+	defaultAverageSamples = 10
+	maxAverageSamples     = 100
 )
 
-func addCapturePoint() {
-	data := getEncoderData()
+// sampleAverage takes n live positions holdSampleEvery apart and returns
+// their mean, as a point to store, and each axis's standard deviation.
+func sampleAverage(n int) (mean, stddev point) {
+	start := time.Now()
+	samples := make([]point, n)
+	for i := range samples {
+		if i > 0 {
+			time.Sleep(holdSampleEvery)
+		}
+		samples[i] = livePoint()
+	}
+	for _, p := range samples {
+		mean.x += p.x / float64(n)
+		mean.y += p.y / float64(n)
+		mean.z += p.z / float64(n)
+	}
+	for _, p := range samples {
+		stddev.x += sq(p.x-mean.x) / float64(n)
+		stddev.y += sq(p.y-mean.y) / float64(n)
+		stddev.z += sq(p.z-mean.z) / float64(n)
+	}
+	stddev.x, stddev.y, stddev.z = math.Sqrt(stddev.x), math.Sqrt(stddev.y), math.Sqrt(stddev.z)
+	mean.source, mean.capturedAt = sourceAverage, start
+	return mean, stddev
+}
+
+// addPoint appends p, stamped now unless it already carries a time, and
+// restarts the capture cooldown.
+func addPoint(p point) {
+	now := clk.now()
+	if p.capturedAt.IsZero() {
+		p.capturedAt = now
+	}
 	pointsMu.Lock()
-	points = append(points, point{
-		x: data.X.Distance,
-		y: data.Y.Distance,
-		z: data.Z.Distance,
-	})
+	active.points = append(active.points, p)
 	pointsMu.Unlock()
-	lastPointAddedTime = time.Now()
+	logPoint(p)
+	lastPointAddedTime.Store(now.UnixNano())
+	pointsCaptured.Add(1)
+	notePointsChanged()
+}
+
+// addManualPoint appends a point at explicit coordinates (mm), e.g. a known
+// datum, with an optional label.
+func addManualPoint(x, y, z float64, label string) {
+	addPoint(point{x: x, y: y, z: z, source: sourceManual, label: label})
+}
+
+// appendPoints adds pts after the existing points in one step, so captures
+// can't interleave with an import.
+func appendPoints(pts []point) {
+	pointsMu.Lock()
+	active.points = append(active.points, pts...)
+	pointsMu.Unlock()
+	notePointsChanged()
+}
+
+// undoLastPoint drops the most recent point and returns how many remain; ok
+// is false when there was nothing to undo.
+func undoLastPoint() (remaining int, ok bool) {
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	if len(active.points) == 0 {
+		return 0, false
+	}
+	active.points = active.points[:len(active.points)-1]
+	notePointsChanged()
+	return len(active.points), true
+}
+
+// deletePoint removes the point at index i (capture order) and returns how
+// many remain; ok is false when i is out of range. Later points shift down.
+func deletePoint(i int) (remaining int, ok bool) {
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	if i < 0 || i >= len(active.points) {
+		return len(active.points), false
+	}
+	active.points = append(active.points[:i], active.points[i+1:]...)
+	notePointsChanged()
+	return len(active.points), true
+}
+
+// setPointLabel names the point at index i (capture order), or clears its
+// label with ""; ok is false when i is out of range.
+func setPointLabel(i int, label string) (n int, ok bool) {
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	if i < 0 || i >= len(active.points) {
+		return len(active.points), false
+	}
+	active.points[i].label = label
+	notePointsChanged()
+	return len(active.points), true
 }
 
 func clearCapturePoints() {
 	pointsMu.Lock()
-	points = []point{}
+	active.points = []point{}
 	pointsMu.Unlock()
+	notePointsChanged()
 }
 
 func capturePointCount() int {
 	pointsMu.RLock()
-	n := len(points)
+	n := len(active.points)
 	pointsMu.RUnlock()
 	return n
 }
 
-func capturePointsASC() (string, error) {
-	pointsMu.Lock()
-	defer pointsMu.Unlock()
-	if len(points) == 0 {
-		return "", fmt.Errorf("no points to save")
-	}
-	var asc string
-	for _, p := range points {
-		asc += fmt.Sprintf("%.6f %.6f %.6f\n", p.x, p.y, p.z)
+// snapshotPoints returns a copy of the active session's points taken under the lock.
+func snapshotPoints() []point {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	return append([]point(nil), active.points...)
+}
+
+// listPoints returns the active session's points in capture order, converted to
+// display units where one unit is scale mm.
+func listPoints(scale float64) []pointJSON {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	return pointList(active.points, scale)
+}
+
+// pointList converts points to their JSON form, in mm per scale.
+func pointList(pts []point, scale float64) []pointJSON {
+	list := make([]pointJSON, len(pts))
+	for i, p := range pts {
+		list[i] = pointJSON{
+			Index:         i,
+			X:             p.x / scale,
+			Y:             p.y / scale,
+			Z:             p.z / scale,
+			Source:        p.source,
+			CapturedAt:    p.capturedAt,
+			Label:         p.label,
+			ProbeRadiusMm: p.probeRadius,
+		}
 	}
-	return asc, nil
+	return list
 }
 
-// captureAllowedSince reports whether at least d has passed since the last capture.
-func captureAllowedSince(d time.Duration) bool {
-	return time.Since(lastPointAddedTime) >= d
+// captureAllowed reports whether the capture cooldown has passed since the last capture.
+func captureAllowed() bool {
+	return clk.now().Sub(time.Unix(0, lastPointAddedTime.Load())) >= getCaptureCooldown()
 }
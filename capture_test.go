@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// withProbeRadius sets the live config's probe radius for the test.
+func withProbeRadius(t *testing.T, r float64) {
+	t.Helper()
+	saved := cfg()
+	c := *saved
+	c.ProbeRadiusMm = r
+	liveConfig.Store(&c)
+	t.Cleanup(func() { liveConfig.Store(saved) })
+}
+
+func TestCompensateProbe(t *testing.T) {
+	tip := point{x: 10, y: 20, z: 5}
+	tests := []struct {
+		name   string
+		radius float64
+		trace  []point
+		want   point
+	}{
+		{"no radius", 0, []point{{x: 0, y: 20, z: 5}}, tip},
+		{"approach along +X", 1, []point{{x: 0, y: 20, z: 5}}, point{x: 11, y: 20, z: 5}},
+		{"approach along -Z", 2, []point{{x: 10, y: 20, z: 15}}, point{x: 10, y: 20, z: 3}},
+		{"latest far sample wins", 1, []point{{x: 10, y: 10, z: 5}, {x: 0, y: 20, z: 5}}, point{x: 11, y: 20, z: 5}},
+		{"near samples skipped", 1, []point{{x: 0, y: 20, z: 5}, {x: 9.8, y: 20, z: 5}, {x: 10, y: 20, z: 5}}, point{x: 11, y: 20, z: 5}},
+		{"diagonal", 1, []point{{x: 7, y: 16, z: 5}}, point{x: 10.6, y: 20.8, z: 5}},
+		{"no sample far enough", 1, []point{{x: 9.9, y: 20, z: 5}}, tip},
+		{"empty trace", 1, nil, tip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withProbeRadius(t, tt.radius)
+			got := compensateProbe(tip, tt.trace)
+			if math.Abs(got.x-tt.want.x) > 1e-9 || math.Abs(got.y-tt.want.y) > 1e-9 || math.Abs(got.z-tt.want.z) > 1e-9 {
+				t.Errorf("compensateProbe = (%v, %v, %v), want (%v, %v, %v)", got.x, got.y, got.z, tt.want.x, tt.want.y, tt.want.z)
+			}
+			wantRadius := tt.radius
+			if got == tip {
+				wantRadius = 0
+			}
+			if got.probeRadius != wantRadius {
+				t.Errorf("probeRadius = %v, want %v", got.probeRadius, wantRadius)
+			}
+		})
+	}
+}
+
+func TestLivePointWhileHeld(t *testing.T) {
+	for _, tt := range []struct {
+		holdCapture string
+		hold        bool
+		wantCount   int32
+	}{
+		{"", false, 1500},
+		{"", true, 1000},
+		{holdCaptureHeld, true, 1000},
+		{holdCaptureLive, true, 1500},
+		{holdCaptureLive, false, 1500},
+	} {
+		t.Run(fmt.Sprintf("%q hold=%v", tt.holdCapture, tt.hold), func(t *testing.T) {
+			enc := newTestEncoder(t)
+			c := *cfg()
+			c.HoldCapture = tt.holdCapture
+			liveConfig.Store(&c)
+			now := time.Now()
+			enc.update(1000, now)
+			setHold(tt.hold)
+			enc.update(1500, now.Add(50*time.Millisecond))
+			enc.mu.RLock()
+			want := enc.countsToMM(int64(tt.wantCount))
+			enc.mu.RUnlock()
+			if got := livePoint().x; math.Abs(got-want) > 1e-9 {
+				t.Errorf("livePoint().x = %v mm, want %v (count %d)", got, want, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestAddManualPoint(t *testing.T) {
+	_, start := withFakeClock(t)
+	withTestSession(t, nil)
+	before := pointsCaptured.Load()
+	addManualPoint(1, 2, 3, "datum")
+	pts := snapshotPoints()
+	if len(pts) != 1 {
+		t.Fatalf("%d points, want 1", len(pts))
+	}
+	p := pts[0]
+	if p.x != 1 || p.y != 2 || p.z != 3 || p.label != "datum" || p.source != sourceManual {
+		t.Errorf("point = %+v", p)
+	}
+	if !p.capturedAt.Equal(start) {
+		t.Errorf("capturedAt = %v, want the clock's %v", p.capturedAt, start)
+	}
+	if got := pointsCaptured.Load() - before; got != 1 {
+		t.Errorf("pointsCaptured went up %d, want 1", got)
+	}
+}
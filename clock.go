@@ -0,0 +1,23 @@
+package main
+
+import "time"
+
+// clock is the time source of the foot switch, capture cooldown, and event
+// replay, so tests can run recorded presses on simulated time.
+type clock interface {
+	now() time.Time
+	afterFunc(d time.Duration, f func()) timer // f runs on its own goroutine
+}
+
+// timer is a pending afterFunc call.
+type timer interface {
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) now() time.Time { return time.Now() }
+
+func (realClock) afterFunc(d time.Duration, f func()) timer { return time.AfterFunc(d, f) }
+
+var clk clock = realClock{}
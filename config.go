@@ -0,0 +1,704 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// config is read from the JSON file named by -config. Anything the file leaves
+// out keeps its default, and a missing file means all defaults.
+type config struct {
+	Pins pinConfig `json:"pins"`
+
+	// Addr is the HTTP listen address, e.g. "0.0.0.0:8080" or
+	// "192.168.1.20:3000" ("" = :3000). The -addr flag overrides it.
+	Addr string `json:"addr,omitempty"`
+
+	// AllowedOrigins are the other web origins whose pages may call the API
+	// from a browser (CORS), e.g. "http://shop-pc:8080", or "*" for any.
+	// Pages on localhost are always allowed; the UI itself needs no CORS.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+
+	// CaptureCooldownMs is the minimum spacing between captures from either
+	// the foot switch or the web UI (0 = default).
+	CaptureCooldownMs int `json:"captureCooldownMs,omitempty"`
+
+	// CaptureToleranceMm rejects a capture within this distance (mm, 3D) of
+	// the previous point: a double-fired switch or the same spot twice.
+	// 0 = off.
+	CaptureToleranceMm float64 `json:"captureToleranceMm,omitempty"`
+
+	// ProbeRadiusMm is the radius of a ball-tip probe. Captures are moved
+	// this far along the approach direction, from the tip's center to the
+	// surface it touched. 0 = off.
+	ProbeRadiusMm float64 `json:"probeRadiusMm,omitempty"`
+
+	// HoldCapture is what a capture records while hold is on: "held", the
+	// frozen reading, or "live", where the probe is now ("" = held).
+	HoldCapture string `json:"holdCapture,omitempty"`
+
+	// ButtonDebounceMs is how long the foot switch must stay put after a
+	// change before the next one counts (0 = default).
+	ButtonDebounceMs int `json:"buttonDebounceMs,omitempty"`
+
+	// ButtonActiveHigh is for a switch wired to 3.3 V with a pull-down, which
+	// reads HIGH when pressed. By default the switch pulls the line to GND
+	// against a pull-up, so it reads LOW when pressed.
+	ButtonActiveHigh bool `json:"buttonActiveHigh,omitempty"`
+
+	// AverageHoldMs turns on hold-to-average for the foot switch: a press held
+	// at least this long stores the mean position sampled while it was down.
+	// Shorter presses capture as usual. 0 disables averaging.
+	AverageHoldMs int `json:"averageHoldMs,omitempty"`
+
+	// LongPressMs makes a foot-switch press held this long zero every axis
+	// instead of capturing; shorter presses capture on release. 0 = off.
+	// It can't be combined with AverageHoldMs, which also uses long presses.
+	LongPressMs int `json:"longPressMs,omitempty"`
+
+	// CloseToleranceMm is how near the last point must be to the first
+	// for close=true exports to close the loop (0 = default).
+	CloseToleranceMm float64 `json:"closeToleranceMm,omitempty"`
+
+	// ExportHeader prepends a metadata block (version, time, operator, units,
+	// point count) to exports; the header query parameter overrides it.
+	ExportHeader bool `json:"exportHeader,omitempty"`
+
+	// AutoUnitHysteresisMm is the band around the 1 m threshold that the
+	// "auto" unit must clear before switching between mm and m (0 = default).
+	AutoUnitHysteresisMm float64 `json:"autoUnitHysteresisMm,omitempty"`
+
+	// RPMSmoothing is the weight (0..1] of each new 50 ms of samples in the
+	// displayed RPM's moving average: lower is steadier but slower, 1 is no
+	// smoothing (0 = default). It is rescaled for other poll intervals.
+	RPMSmoothing float64 `json:"rpmSmoothing,omitempty"`
+
+	// RPMDeadbandCounts treats per-sample count changes this small as no
+	// motion, so a wheel resting on an edge doesn't read as turning. 0 = off.
+	RPMDeadbandCounts int `json:"rpmDeadbandCounts,omitempty"`
+
+	// PollMs is how often the counters are sampled, which is also how often
+	// RPM and velocity update (0 = default). Shorter is more responsive,
+	// longer is steadier.
+	PollMs int `json:"pollMs,omitempty"`
+
+	// FractionDenominator is the finest fraction of an inch the ft display
+	// shows: 16, 32, or 64 (0 = 16).
+	FractionDenominator int `json:"fractionDenominator,omitempty"`
+
+	// Metrics serves Prometheus metrics at /metrics.
+	Metrics bool `json:"metrics,omitempty"`
+
+	// AutosavePath turns on autosave: captured points are written to this
+	// JSON file (at most once a second) and restored from it at startup.
+	AutosavePath string `json:"autosavePath,omitempty"`
+
+	// RecordEventsPath turns on the GPIO event recorder: every foot-switch
+	// edge is appended to this file, which the mock backend can replay.
+	RecordEventsPath string `json:"recordEventsPath,omitempty"`
+
+	// PointLogPath turns on the point log: each captured or manually
+	// entered point is appended to this file as an ASC line.
+	PointLogPath string `json:"pointLogPath,omitempty"`
+
+	// PathSampleMs is how often the probe position is sampled for the path
+	// trace (0 = default). PathLength is how many samples the trace keeps
+	// (0 = default).
+	PathSampleMs int `json:"pathSampleMs,omitempty"`
+	PathLength   int `json:"pathLength,omitempty"`
+
+	// PushHz caps how many times a second live clients (WebSocket and SSE)
+	// are sent readings (0 = default). Updates in between are coalesced.
+	PushHz int `json:"pushHz,omitempty"`
+
+	// AxisLabels names the axis on each pins.chipSelects counter, in order
+	// (default X, X', Y, Z). X, Y, and Z are the captured point coordinates;
+	// X' pairs with X for racking. Other labels, e.g. "A" for a rotary or
+	// "W" for a second Z, are shown and served but not captured.
+	AxisLabels []string `json:"axisLabels,omitempty"`
+
+	Axes map[string]axisConfig `json:"axes,omitempty"` // keyed by axis label
+}
+
+// Capture cooldown bounds accepted from config and /api/config/cooldown.
+const (
+	minCaptureCooldown = 50 * time.Millisecond
+	maxCaptureCooldown = 5 * time.Second
+
+	defaultCloseToleranceMm = 5.0
+
+	defaultAutoUnitHysteresisMm = 50.0
+
+	defaultRPMSmoothing = 0.3
+
+	minPoll     = 10 * time.Millisecond
+	maxPoll     = time.Second
+	defaultPoll = 50 * time.Millisecond
+
+	defaultFractionDenominator = 16
+
+	defaultAddr = ":3000"
+
+	minPathSample     = 10 * time.Millisecond
+	maxPathSample     = 10 * time.Second
+	defaultPathSample = 100 * time.Millisecond
+	maxPathLength     = 100000
+	defaultPathLength = 1000
+
+	maxProbeRadius = 50.0 // mm
+
+	minButtonDebounce     = 1 * time.Millisecond
+	maxButtonDebounce     = 500 * time.Millisecond
+	defaultButtonDebounce = 50 * time.Millisecond
+
+	minLongPress = 200 * time.Millisecond
+	maxLongPress = 10 * time.Second
+
+	minAverageHold = 2 * holdSampleEvery // at least two samples to average
+	maxAverageHold = 10 * time.Second
+
+	maxPushHz     = 100
+	defaultPushHz = 30
+)
+
+// pinConfig is the GPIO wiring. The defaults match the counter HAT (HARDWARE.md).
+type pinConfig struct {
+	Chip        string `json:"chip"`        // GPIO character device
+	ChipSelects []int  `json:"chipSelects"` // SS/ per counter, U1 first; one per axis label
+	PointButton int    `json:"pointButton"` // foot switch input
+}
+
+// Pins the HAT needs regardless of config: GPCLK0 and SPI0 MISO/MOSI/SCLK.
+var fixedPins = map[int]string{4: "GPCLK0", 9: "SPI0 MISO", 10: "SPI0 MOSI", 11: "SPI0 SCLK"}
+
+// validate rejects wiring that reuses a pin or doesn't name one chip select per counter.
+func (p pinConfig) validate() error {
+	if p.Chip == "" {
+		return fmt.Errorf("pins.chip is empty")
+	}
+	if len(p.ChipSelects) == 0 {
+		return fmt.Errorf("pins.chipSelects is empty (want one per axis)")
+	}
+	used := map[int]string{}
+	for pin, use := range fixedPins {
+		used[pin] = use
+	}
+	claim := func(pin int, use string) error {
+		if pin < 0 || pin > 27 {
+			return fmt.Errorf("%s: GPIO%d is not a header GPIO (0..27)", use, pin)
+		}
+		if other, ok := used[pin]; ok {
+			return fmt.Errorf("%s: GPIO%d is already used by %s", use, pin, other)
+		}
+		used[pin] = use
+		return nil
+	}
+	for i, pin := range p.ChipSelects {
+		if err := claim(pin, fmt.Sprintf("U%d chip select", i+1)); err != nil {
+			return err
+		}
+	}
+	return claim(p.PointButton, "point button")
+}
+
+// axisConfig holds per-axis settings.
+type axisConfig struct {
+	// CountsPerRev is encoder PPR × 4 (x4 quadrature); 0 = 2400 (600 PPR).
+	CountsPerRev float64 `json:"countsPerRev,omitempty"`
+
+	// WheelDiameterMm is the measuring wheel diameter; 0 = 50 mm.
+	WheelDiameterMm float64 `json:"wheelDiameterMm,omitempty"`
+
+	// MaxDistance clamps the displayed distance to ±MaxDistance mm and flags
+	// the card; raw data is untouched. 0 disables clamping.
+	MaxDistance float64 `json:"maxDistance,omitempty"`
+
+	// SwapAB reinterprets the axis as if the A and B leads were swapped.
+	// The LS7366R decodes quadrature in hardware, so there is no transition
+	// table to re-index: swapping A/B is exactly a direction reversal and is
+	// applied by negating the counter as it is read.
+	SwapAB bool `json:"swapAB,omitempty"`
+
+	// Index says the encoder's index (Z) output is wired to the chip's INDEX/
+	// pin (HARDWARE.md), which enables homing on this axis. HomeCount is the
+	// count the axis is set to when homing sees the index pulse.
+	Index     bool `json:"index,omitempty"`
+	HomeCount int  `json:"homeCount,omitempty"`
+
+	// FilterDivide is the LS7366R input filter clock divider, 1 or 2 (0 = 1).
+	// A and B are not GPIO lines, so there is no software debounce. The
+	// chip's digital filter drops pulses shorter than a few filter-clock
+	// periods. Dividing by 2 doubles the rejected glitch width and halves the
+	// maximum count rate. Mechanical bounce on a quadrature edge counts up
+	// and back down again, so it cannot inflate the count anyway.
+	FilterDivide int `json:"filterDivide,omitempty"`
+
+	// Quadrature is how many A/B edges the LS7366R counts per encoder
+	// cycle: 4 (every edge), 2 (both edges of A), or 1 (one edge of A)
+	// (0 = 4). Fewer edges means less resolution but fewer counts from
+	// vibration at an edge. CountsPerRev stays the x4 figure; distances
+	// scale by quadrature/4.
+	Quadrature int `json:"quadrature,omitempty"`
+
+	// Enabled false turns the axis off, e.g. Z on a two-axis build: it isn't
+	// read, its chip select isn't requested, and it drops out of the UI and
+	// API. Omitted means enabled.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Diameter shows the axis as a diameter (twice the travel), as on a lathe
+	// cross slide. Only the displayed distance and presets change; counts
+	// and captured points stay as measured.
+	Diameter bool `json:"diameter,omitempty"`
+
+	// Calibration multiplies the axis's distances to correct a small scale
+	// error, e.g. 100/99.7 for a wheel that reads 99.7 mm over 100 mm.
+	// 0 = 1 (uncorrected); otherwise it must be within 0.5..2.
+	Calibration float64 `json:"calibration,omitempty"`
+
+	// BacklashCounts is the play in the axis's drive: after a reversal the
+	// encoder turns this many counts before the axis moves, so the display
+	// holds still for them. 0 = no compensation.
+	BacklashCounts int `json:"backlashCounts,omitempty"`
+
+	// MaxJumpCounts is the most the count can plausibly change between two
+	// samples (one poll interval). A bigger jump is taken as the wheel
+	// slipping or the probe being bumped: it is logged and left out of the
+	// displayed distance, and the axis is flagged suspect. 0 = off.
+	MaxJumpCounts int `json:"maxJumpCounts,omitempty"`
+
+	// Rotary reports the axis as an angle, for an encoder on a rotary table
+	// or spindle: degrees = count / countsPerRev × 360. The wheel diameter is
+	// ignored. Wrap folds the angle into [0, 360).
+	Rotary bool `json:"rotary,omitempty"`
+	Wrap   bool `json:"wrap,omitempty"`
+
+	// Unit fixes the axis's display unit (mm, cm, m, in, thou, ft, or auto)
+	// whatever the page's unit, e.g. "in" for an imperial depth gauge on Z.
+	// "" follows the page.
+	Unit string `json:"unit,omitempty"`
+}
+
+var (
+	liveConfig atomic.Pointer[config] // read with cfg; replaced whole by loadConfig and updateConfig
+	cfgPath    string                 // file cfg was loaded from ("" when none)
+	cfgMu      sync.Mutex             // serializes updateConfig
+)
+
+func init() {
+	c := defaultConfig()
+	liveConfig.Store(&c)
+}
+
+// cfg returns the live config. It is never modified once published, so the
+// poll loop, exporters, and handlers can read it without a lock; changes
+// go through updateConfig.
+func cfg() *config {
+	return liveConfig.Load()
+}
+
+func defaultConfig() config {
+	return config{
+		Pins: pinConfig{
+			Chip:        "gpiochip0",
+			ChipSelects: []int{8, 7, 5, 6},
+			PointButton: 26,
+		},
+		Axes: map[string]axisConfig{},
+	}
+}
+
+var defaultAxisLabels = []string{"X", "X'", "Y", "Z"}
+
+// axisLabel is what an axis label may look like: short, and safe in a URL
+// path and a Prometheus label.
+var axisLabel = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9'_-]{0,15}$`)
+
+// reservedAxisLabels are the fixed routes under /api/encoder/, which would
+// shadow, or be shadowed by, /api/encoder/:axis for an axis of that name.
+var reservedAxisLabels = []string{"config", "formatted", "hold", "htmx", "mode", "origin", "rates", "stream", "zero"}
+
+// axisLabels returns the axis label of each counter in chip order.
+func (c config) axisLabels() []string {
+	if len(c.AxisLabels) > 0 {
+		return c.AxisLabels
+	}
+	return defaultAxisLabels
+}
+
+// validateAxisLabels checks there is one well-formed label per chip select
+// and that no two labels, nor a label and a fixed route, collide in a
+// case-insensitive URL.
+func (c config) validateAxisLabels() error {
+	labels := c.axisLabels()
+	if len(labels) != len(c.Pins.ChipSelects) {
+		return fmt.Errorf("axisLabels: got %d labels for %d pins.chipSelects", len(labels), len(c.Pins.ChipSelects))
+	}
+	seen := map[string]bool{}
+	for _, label := range labels {
+		if !axisLabel.MatchString(label) {
+			return fmt.Errorf("axisLabels: %q is not a letter followed by up to 15 letters, digits, ', _ or -", label)
+		}
+		key := strings.ToLower(label)
+		if slices.Contains(reservedAxisLabels, key) {
+			return fmt.Errorf("axisLabels: %q is reserved for /api/encoder/%s", label, key)
+		}
+		if key == "xp" { // URL alias for X'
+			key = "x'"
+		}
+		if seen[key] {
+			return fmt.Errorf("axisLabels: %q is used twice (labels are case-insensitive, and xp means X')", label)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// axis returns the settings for the encoder labelled label.
+func (c config) axis(label string) axisConfig {
+	return c.Axes[label]
+}
+
+// closeTolerance returns the loop-closing tolerance for exports in mm.
+func (c config) closeTolerance() float64 {
+	if c.CloseToleranceMm > 0 {
+		return c.CloseToleranceMm
+	}
+	return defaultCloseToleranceMm
+}
+
+// autoUnitHysteresis returns the auto-unit switching band in mm.
+func (c config) autoUnitHysteresis() float64 {
+	if c.AutoUnitHysteresisMm > 0 {
+		return c.AutoUnitHysteresisMm
+	}
+	return defaultAutoUnitHysteresisMm
+}
+
+// HoldCapture values.
+const (
+	holdCaptureHeld = "held"
+	holdCaptureLive = "live"
+)
+
+// captureLiveWhileHeld reports whether captures during hold take the live
+// position rather than the held one.
+func (c config) captureLiveWhileHeld() bool {
+	return c.HoldCapture == holdCaptureLive
+}
+
+// averageHold returns the foot-switch hold-to-average threshold; 0 means off.
+func (c config) averageHold() time.Duration {
+	return time.Duration(c.AverageHoldMs) * time.Millisecond
+}
+
+// longPress returns the foot-switch long-press threshold; 0 means off.
+func (c config) longPress() time.Duration {
+	return time.Duration(c.LongPressMs) * time.Millisecond
+}
+
+// buttonDebounce returns the foot-switch debounce window.
+func (c config) buttonDebounce() time.Duration {
+	if c.ButtonDebounceMs > 0 {
+		return time.Duration(c.ButtonDebounceMs) * time.Millisecond
+	}
+	return defaultButtonDebounce
+}
+
+// rpmSmoothing returns the RPM moving-average weight.
+func (c config) rpmSmoothing() float64 {
+	if c.RPMSmoothing > 0 && c.RPMSmoothing <= 1 {
+		return c.RPMSmoothing
+	}
+	return defaultRPMSmoothing
+}
+
+// poll returns the counter sampling interval.
+func (c config) poll() time.Duration {
+	if c.PollMs > 0 {
+		return time.Duration(c.PollMs) * time.Millisecond
+	}
+	return defaultPoll
+}
+
+// rpmAlpha is the RPM moving-average weight of one sample: rpmSmoothing
+// rescaled from 50 ms to the poll interval, so the smoothing time constant
+// stays the same whatever the interval.
+func (c config) rpmAlpha() float64 {
+	return 1 - math.Pow(1-c.rpmSmoothing(), float64(c.poll())/float64(defaultPoll))
+}
+
+// listenAddr returns the HTTP listen address.
+func (c config) listenAddr() string {
+	if c.Addr != "" {
+		return c.Addr
+	}
+	return defaultAddr
+}
+
+// allowsOrigin reports whether a browser page from origin may call the API.
+func (c config) allowsOrigin(origin string) bool {
+	if slices.ContainsFunc(c.AllowedOrigins, func(o string) bool {
+		return o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin)
+	}) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// validateAllowedOrigins wants each origin to be "*" or scheme://host[:port].
+func (c config) validateAllowedOrigins() error {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			return fmt.Errorf("allowedOrigins: %q is not an origin like http://host:port", o)
+		}
+	}
+	return nil
+}
+
+// fractionDenominator returns the ft display's fraction resolution.
+func (c config) fractionDenominator() int {
+	if c.FractionDenominator > 0 {
+		return c.FractionDenominator
+	}
+	return defaultFractionDenominator
+}
+
+// pushInterval returns the minimum spacing between live-client pushes.
+func (c config) pushInterval() time.Duration {
+	hz := c.PushHz
+	if hz <= 0 {
+		hz = defaultPushHz
+	}
+	return time.Second / time.Duration(hz)
+}
+
+// pathSample returns the path trace's sampling interval.
+func (c config) pathSample() time.Duration {
+	if c.PathSampleMs > 0 {
+		return time.Duration(c.PathSampleMs) * time.Millisecond
+	}
+	return defaultPathSample
+}
+
+// pathLength returns how many samples the path trace keeps.
+func (c config) pathLength() int {
+	if c.PathLength > 0 {
+		return c.PathLength
+	}
+	return defaultPathLength
+}
+
+func (a axisConfig) enabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+func (a axisConfig) calibration() float64 {
+	if a.Calibration > 0 {
+		return a.Calibration
+	}
+	return 1
+}
+
+func (a axisConfig) filterDivide() int {
+	if a.FilterDivide == 2 {
+		return 2
+	}
+	return 1
+}
+
+func (a axisConfig) quadrature() int {
+	if a.Quadrature == 0 {
+		return 4
+	}
+	return a.Quadrature
+}
+
+func (a axisConfig) countsPerRev() float64 {
+	if a.CountsPerRev > 0 {
+		return a.CountsPerRev
+	}
+	return defaultCountsPerRev
+}
+
+func (a axisConfig) wheelDiameter() float64 {
+	if a.WheelDiameterMm > 0 {
+		return a.WheelDiameterMm
+	}
+	return defaultWheelDiameter
+}
+
+// loadConfig overlays the JSON file at path onto the defaults.
+func loadConfig(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info("config not found, using defaults", "path", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	c := defaultConfig()
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("parse config %s: %w", path, err)
+	}
+	if err := c.Pins.validate(); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	for label, ac := range c.Axes {
+		if ac.FilterDivide < 0 || ac.FilterDivide > 2 {
+			return fmt.Errorf("config %s: axes.%s.filterDivide: got %d, want 1 or 2", path, label, ac.FilterDivide)
+		}
+		switch ac.Quadrature {
+		case 0, 1, 2, 4:
+		default:
+			return fmt.Errorf("config %s: axes.%s.quadrature: got %d, want 1, 2, or 4", path, label, ac.Quadrature)
+		}
+		if ac.BacklashCounts < 0 || ac.BacklashCounts > maxBacklashCounts {
+			return fmt.Errorf("config %s: axes.%s.backlashCounts: %d out of range 0..%d", path, label, ac.BacklashCounts, maxBacklashCounts)
+		}
+		if ac.MaxJumpCounts < 0 {
+			return fmt.Errorf("config %s: axes.%s.maxJumpCounts: %d is negative", path, label, ac.MaxJumpCounts)
+		}
+		if ac.Unit != "" && validUnit(ac.Unit) != ac.Unit {
+			return fmt.Errorf("config %s: axes.%s.unit: unknown unit %q", path, label, ac.Unit)
+		}
+		if ac.Calibration != 0 && !validCalibration(ac.Calibration) {
+			return fmt.Errorf("config %s: axes.%s.calibration: %v out of range %v..%v", path, label, ac.Calibration, minCalibration, maxCalibration)
+		}
+	}
+	if err := c.validateAllowedOrigins(); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	if err := c.validateAxisLabels(); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	for label := range c.Axes {
+		if !slices.Contains(c.axisLabels(), label) {
+			return fmt.Errorf("config %s: axes.%s: no axis has that label (axisLabels %q)", path, label, c.axisLabels())
+		}
+	}
+	if !slices.ContainsFunc(c.axisLabels(), func(label string) bool { return c.axis(label).enabled() }) {
+		return fmt.Errorf("config %s: every axis is disabled", path)
+	}
+	if c.ButtonDebounceMs != 0 {
+		if d := c.buttonDebounce(); d < minButtonDebounce || d > maxButtonDebounce {
+			return fmt.Errorf("config %s: buttonDebounceMs: %v out of range %v..%v", path, d, minButtonDebounce, maxButtonDebounce)
+		}
+	}
+	if c.AverageHoldMs != 0 {
+		if d := c.averageHold(); d < minAverageHold || d > maxAverageHold {
+			return fmt.Errorf("config %s: averageHoldMs: %v out of range %v..%v", path, d, minAverageHold, maxAverageHold)
+		}
+	}
+	if c.LongPressMs != 0 {
+		if d := c.longPress(); d < minLongPress || d > maxLongPress {
+			return fmt.Errorf("config %s: longPressMs: %v out of range %v..%v", path, d, minLongPress, maxLongPress)
+		}
+		if c.AverageHoldMs > 0 {
+			return fmt.Errorf("config %s: longPressMs and averageHoldMs both act on held presses; set only one", path)
+		}
+	}
+	switch c.HoldCapture {
+	case "", holdCaptureHeld, holdCaptureLive:
+	default:
+		return fmt.Errorf("config %s: holdCapture: got %q, want %q or %q", path, c.HoldCapture, holdCaptureHeld, holdCaptureLive)
+	}
+	switch c.FractionDenominator {
+	case 0, 16, 32, 64:
+	default:
+		return fmt.Errorf("config %s: fractionDenominator: got %d, want 16, 32, or 64", path, c.FractionDenominator)
+	}
+	if c.PathSampleMs != 0 {
+		if d := time.Duration(c.PathSampleMs) * time.Millisecond; d < minPathSample || d > maxPathSample {
+			return fmt.Errorf("config %s: pathSampleMs: %v out of range %v..%v", path, d, minPathSample, maxPathSample)
+		}
+	}
+	if c.PushHz < 0 || c.PushHz > maxPushHz {
+		return fmt.Errorf("config %s: pushHz: %d out of range 0..%d (0 = default)", path, c.PushHz, maxPushHz)
+	}
+	if c.PathLength < 0 || c.PathLength > maxPathLength {
+		return fmt.Errorf("config %s: pathLength: %d out of range 0..%d (0 = default)", path, c.PathLength, maxPathLength)
+	}
+	if c.PollMs != 0 {
+		if d := c.poll(); d < minPoll || d > maxPoll {
+			return fmt.Errorf("config %s: pollMs: %v out of range %v..%v", path, d, minPoll, maxPoll)
+		}
+	}
+	if c.RPMSmoothing < 0 || c.RPMSmoothing > 1 {
+		return fmt.Errorf("config %s: rpmSmoothing %v out of range (0, 1]", path, c.RPMSmoothing)
+	}
+	if c.CaptureCooldownMs != 0 {
+		if err := validateCaptureCooldown(time.Duration(c.CaptureCooldownMs) * time.Millisecond); err != nil {
+			return fmt.Errorf("config %s: captureCooldownMs: %w", path, err)
+		}
+	}
+	if !(c.ProbeRadiusMm >= 0 && c.ProbeRadiusMm <= maxProbeRadius) {
+		return fmt.Errorf("config %s: probeRadiusMm %v out of range 0..%v", path, c.ProbeRadiusMm, maxProbeRadius)
+	}
+	if c.CaptureToleranceMm < 0 || math.IsNaN(c.CaptureToleranceMm) {
+		return fmt.Errorf("config %s: captureToleranceMm: %v is negative", path, c.CaptureToleranceMm)
+	}
+	liveConfig.Store(&c)
+	cfgPath = path
+	return nil
+}
+
+// updateConfig applies fn to a copy of the config and publishes it and,
+// when the config came from a file, writes the result back so runtime
+// changes survive a restart. fn may change Axes entries; the map is copied.
+func updateConfig(fn func(*config)) error {
+	cfgMu.Lock()
+	defer cfgMu.Unlock()
+	c := *cfg()
+	c.Axes = maps.Clone(c.Axes)
+	if c.Axes == nil {
+		c.Axes = map[string]axisConfig{}
+	}
+	fn(&c)
+	liveConfig.Store(&c)
+	if cfgPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(&c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(cfgPath, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+	return nil
+}
+
+func validateCaptureCooldown(d time.Duration) error {
+	if d < minCaptureCooldown || d > maxCaptureCooldown {
+		return fmt.Errorf("%v out of range %v..%v", d, minCaptureCooldown, maxCaptureCooldown)
+	}
+	return nil
+}
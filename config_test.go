@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigHoldCapture(t *testing.T) {
+	saved, savedPath := cfg(), cfgPath
+	t.Cleanup(func() {
+		liveConfig.Store(saved)
+		cfgPath = savedPath
+	})
+	for _, tt := range []struct {
+		json    string
+		want    bool // captures live while held
+		wantErr bool
+	}{
+		{`{}`, false, false},
+		{`{"holdCapture": "held"}`, false, false},
+		{`{"holdCapture": "live"}`, true, false},
+		{`{"holdCapture": "frozen"}`, false, true},
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		liveConfig.Store(saved)
+		err := loadConfig(path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: loadConfig error = %v, want error %v", tt.json, err, tt.wantErr)
+			continue
+		}
+		if err == nil && cfg().captureLiveWhileHeld() != tt.want {
+			t.Errorf("%s: captureLiveWhileHeld = %v, want %v", tt.json, !tt.want, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigAverageHold(t *testing.T) {
+	saved, savedPath := cfg(), cfgPath
+	t.Cleanup(func() {
+		liveConfig.Store(saved)
+		cfgPath = savedPath
+	})
+	for _, tt := range []struct {
+		json    string
+		wantErr bool
+	}{
+		{`{}`, false},
+		{`{"averageHoldMs": 500}`, false},
+		{`{"averageHoldMs": 100}`, false},
+		{`{"averageHoldMs": 10000}`, false},
+		{`{"averageHoldMs": -1}`, true},
+		{`{"averageHoldMs": 50}`, true},
+		{`{"averageHoldMs": 10001}`, true},
+		{`{"averageHoldMs": 500, "longPressMs": 1000}`, true},
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		liveConfig.Store(saved)
+		if err := loadConfig(path); (err != nil) != tt.wantErr {
+			t.Errorf("%s: loadConfig error = %v, want error %v", tt.json, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateAxisLabelsReserved(t *testing.T) {
+	for _, tt := range []struct {
+		labels  []string
+		wantErr bool
+	}{
+		{[]string{"X", "Y", "Z", "A"}, false},
+		{[]string{"X", "Y", "Z", "Rate"}, false},
+		{[]string{"X", "Y", "Z", "rates"}, true},
+		{[]string{"X", "Y", "Z", "Config"}, true},
+		{[]string{"X", "Y", "Z", "HTMX"}, true},
+		{[]string{"X", "Y", "Z", "stream"}, true},
+		{[]string{"X", "Y", "Z", "Formatted"}, true},
+		{[]string{"X", "Y", "Z", "hold"}, true},
+		{[]string{"X", "Y", "Z", "zero"}, true},
+	} {
+		c := config{AxisLabels: tt.labels}
+		c.Pins.ChipSelects = []int{8, 7, 25, 24}
+		if err := c.validateAxisLabels(); (err != nil) != tt.wantErr {
+			t.Errorf("%q: validateAxisLabels error = %v, want error %v", tt.labels, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoadConfigUnknownAxis(t *testing.T) {
+	saved, savedPath := cfg(), cfgPath
+	t.Cleanup(func() {
+		liveConfig.Store(saved)
+		cfgPath = savedPath
+	})
+	for _, tt := range []struct {
+		json    string
+		wantErr bool
+	}{
+		{`{"axes": {"X": {"maxDistance": 5000}, "X'": {}}}`, false},
+		{`{"axes": {"x": {"maxDistance": 5000}}}`, true},
+		{`{"axes": {"Xp": {}}}`, true},
+		{`{"axes": {"A": {}}}`, true},
+		{`{"axisLabels": ["X", "Y", "Z", "A"], "pins": {"chipSelects": [8, 7, 25, 24]}, "axes": {"A": {}}}`, false},
+		{`{"axisLabels": ["X", "Y", "Z", "A"], "pins": {"chipSelects": [8, 7, 25, 24]}, "axes": {"X'": {}}}`, true},
+	} {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(tt.json), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		liveConfig.Store(saved)
+		if err := loadConfig(path); (err != nil) != tt.wantErr {
+			t.Errorf("%s: loadConfig error = %v, want error %v", tt.json, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// GPCLK0 divider for the LS7366R filter clock: 19.2 MHz / (DIVI + DIVF/4096).
+// DIVI=2, DIVF=546 → ~9 MHz. Programmed by gpclk.go on the Pi.
+const (
+	gpclkDivInt  = 2
+	gpclkDivFrac = 546
+
+	gpclkHz = 19.2e6 / (gpclkDivInt + gpclkDivFrac/4096.0) // ≈ 9.0 MHz
+)
+
+// counterSource is where the four quadrature counts come from: the LS7366R
+// bank on the Pi, or a simulated bank for development off-Pi.
+type counterSource interface {
+	readCounter(chip int) (int32, error)
+	armIndex(chip int, count int32) error // next index pulse sets the count
+	indexFired(chip int) (bool, error)    // an armed index pulse has hit; disarms
+	close()
+}
+
+var (
+	counters   counterSource
+	countersMu sync.Mutex // serializes counter access (shared SPI bus)
+)
+
+// useMockBackend reports whether CLOSINUF_BACKEND=mock selects the simulated
+// counters (no SPI, GPCLK, or GPIO needed).
+func useMockBackend() bool {
+	return os.Getenv("CLOSINUF_BACKEND") == "mock"
+}
+
+// closeCounters releases the chip-select lines and SPI device on shutdown.
+// The poll loop skips its reads from then on.
+func closeCounters() {
+	countersMu.Lock()
+	defer countersMu.Unlock()
+	if counters != nil {
+		counters.close()
+		counters = nil
+	}
+}
+
+// openCounterSource picks the counter backend.
+func openCounterSource() (counterSource, error) {
+	if useMockBackend() {
+		slog.Info("using mock counter backend (CLOSINUF_BACKEND=mock)")
+		m := newMockCounters(len(encoders))
+		for chip, enc := range encoders {
+			m.edges[chip] = enc.quadrature
+		}
+		return m, nil
+	}
+	return initCounters()
+}
+
+// sampleCounter reads chip's count. For a homing axis it then checks the
+// index latch: the pulse may have loaded the count just before or just after
+// the read, so when it has fired the count is read again, and fired reports
+// that it is the loaded count rather than motion. Callers hold countersMu.
+func sampleCounter(src counterSource, chip int, homing bool) (count int32, fired bool, err error) {
+	count, err = src.readCounter(chip)
+	if err != nil || !homing {
+		return count, false, err
+	}
+	fired, ierr := src.indexFired(chip)
+	if ierr != nil {
+		slog.Warn("index check failed", "chip", chip+1, "err", ierr)
+	}
+	if fired {
+		count, err = src.readCounter(chip)
+	}
+	return count, fired, err
+}
+
+// pollCountersForever samples the counters every cfg().poll until ctx is
+// cancelled.
+func pollCountersForever(ctx context.Context) {
+	ticker := time.NewTicker(cfg().poll())
+	defer ticker.Stop()
+
+	// An index pulse seen on a poll whose count couldn't be read homes the
+	// axis on the next good read.
+	pendingHome := make([]bool, len(encoders))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// Latch all four counters back to back so the axes share one sample time,
+		// then update the encoders without holding the SPI bus.
+		counts := make([]int32, len(encoders))
+		ok := make([]bool, len(encoders))
+		homed := make([]bool, len(encoders))
+		countersMu.Lock()
+		if counters == nil {
+			countersMu.Unlock()
+			continue
+		}
+		for _, enc := range enabledEncoders() {
+			chip := enc.chip
+			count, fired, err := sampleCounter(counters, chip, enc.isHoming())
+			pendingHome[chip] = pendingHome[chip] || fired
+			if err != nil {
+				slog.Warn("READ_CNTR failed", "chip", chip+1, "err", err)
+				continue
+			}
+			counts[chip], ok[chip], homed[chip] = count, true, pendingHome[chip]
+			pendingHome[chip] = false
+		}
+		now := time.Now()
+		countersMu.Unlock()
+
+		for _, enc := range enabledEncoders() {
+			chip := enc.chip
+			switch {
+			case ok[chip] && homed[chip]:
+				enc.homed(counts[chip], now)
+			case ok[chip]:
+				enc.update(counts[chip], now)
+			default:
+				enc.readFailed()
+			}
+		}
+	}
+}
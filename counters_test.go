@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+// racingIndex is a one-chip counter whose index pulse loads preload just
+// before call number pulseAt (0 = the first readCounter), so a test can put
+// the pulse on either side of the read.
+type racingIndex struct {
+	count, preload int32
+	pulseAt        int
+	calls          int
+	latched        bool
+}
+
+func (r *racingIndex) tick() {
+	if r.calls == r.pulseAt {
+		r.count, r.latched = r.preload, true
+	}
+	r.calls++
+}
+
+func (r *racingIndex) readCounter(int) (int32, error) {
+	r.tick()
+	return r.count, nil
+}
+
+func (r *racingIndex) indexFired(int) (bool, error) {
+	r.tick()
+	fired := r.latched
+	r.latched = false
+	return fired, nil
+}
+
+func (r *racingIndex) armIndex(int, int32) error { return nil }
+func (r *racingIndex) close()                    {}
+
+func TestSampleCounterIndexRace(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		homing    bool
+		pulseAt   int
+		wantCount int32
+		wantFired bool
+	}{
+		{"not homing", false, -1, 5000, false},
+		{"no pulse", true, -1, 5000, false},
+		{"pulse before read", true, 0, 100, true},
+		{"pulse between read and check", true, 1, 100, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &racingIndex{count: 5000, preload: 100, pulseAt: tc.pulseAt}
+			count, fired, err := sampleCounter(src, 0, tc.homing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != tc.wantCount || fired != tc.wantFired {
+				t.Errorf("sampleCounter = %d, %v; want %d, %v", count, fired, tc.wantCount, tc.wantFired)
+			}
+		})
+	}
+}
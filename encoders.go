@@ -1,95 +1,1090 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
 	"math"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type encoder struct {
-	counter       int // hardware counter value (signed)
+	counter       int64 // hardware count extended to 64 bits, never rewritten by zeroing
+	hw            int32 // last LS7366R register value, to unwrap its 32-bit count
+	offset        int64 // datum: counter value that reads as zero distance
+	incOffset     int64 // incremental zero: counter value that reads as zero in INC mode
 	lastReadTime  time.Time
-	lastReadCount int
-	rpm           float64
+	lastReadCount int64
+	rpm           float64   // smoothed for display
+	rpmInstant    float64   // from the last sample alone
+	rateStart     time.Time // start of the current count-rate window
+	rateCounts    int64     // |Δcount| accumulated in the current window
+	rateReversals int       // direction reversals in the current window
+	rateErrors    int       // failed reads in the current window
+	noisy         bool      // the last complete window chattered or had failed reads
+	countRate     float64   // counts/s over the last complete window
+	peakRPM       float64   // largest |rpm| since the last peaks reset
+	peakCountRate float64   // largest countRate since the last peaks reset
+	totalTravel   float64   // odometer: mm moved either way since the last travel reset
+	travelNoted   float64   // totalTravel when autosave was last woken for it
+	readErrors    int       // failed READ_CNTR transfers since the last errors reset
+	errorStreak   int       // consecutive failed reads; 0 after a good one
+	maxDistance   float64   // display clamp in mm (0 = off)
+	clamped       bool      // |distance from the datum| exceeded maxDistance at the last sample, for logging
+	swapAB        bool      // A/B leads swapped: negate hardware counts
+	index         bool      // index output wired to INDEX/, homing possible
+	homeCount     int       // count set by the index pulse when homing
+	homing        bool      // armed, waiting for the index pulse
+	filterDivide  int       // LS7366R filter clock divider (1 or 2)
+	diameter      bool      // lathe diameter mode: display twice the travel
+	disabled      bool      // turned off in config: never read or shown
+	rotary        bool      // report an angle rather than a distance
+	wrap          bool      // fold the rotary angle into [0, 360)
+	unit          string    // display unit fixed for this axis; "" follows the page
+	autoUnit      string    // sticky mm/m choice for the "auto" display unit
+	version       uint64    // encoderVersion when position or rpm last changed
+	countsPerRev  float64   // configured counts per wheel revolution (PPR × 4)
+	quadrature    int       // edges counted per A/B cycle: 1, 2, or 4
+	circumference float64   // wheel circumference in mm
+	calibration   float64   // scale correction applied to distances (1 = none)
+	calStart      int64     // hardware count when a guided calibration started
+	backlash      int       // counts of play taken up after a reversal before the axis moves
+	direction     int       // +1 or -1, the last direction of travel; 0 before any
+	lashLeft      int64     // play still to take up since the last reversal
+	lash          int64     // counts absorbed as play so far, left out of the display
+	maxJump       int       // largest plausible per-sample change; bigger is a slip (0 = off)
+	slip          int64     // counts left out of the display as suspected slips
+	suspect       bool      // a slip was left out since the axis was last zeroed or homed
+	travel        int       // +1 or -1 while moving, 0 once stopped for directionTimeout
+	heldCount     int64     // compensated count when hold went on
+	movedAt       time.Time // last sample that moved beyond the RPM deadband
+	calibrating   bool      // a guided calibration is in progress
 	label         string
-	chip          int // 0..3 → U1..U4
+	chip          int // index into encoders and pins.chipSelects: 0 → U1
 	mu            sync.RWMutex
 }
 
+// Defaults for axes the config doesn't override.
 const (
-	countsPerRev       = 2400.0                  // 600 PPR × 4 (full quadrature)
-	wheelDiameter      = 50.0                    // wheel diameter in mm
-	wheelCircumference = math.Pi * wheelDiameter // ≈ 157.08mm
+	defaultCountsPerRev       = 2400.0                         // 600 PPR × 4 (full quadrature)
+	defaultWheelDiameter      = 50.0                           // wheel diameter in mm
+	defaultWheelCircumference = math.Pi * defaultWheelDiameter // ≈ 157.08mm
 )
 
+const (
+	rateWindow = time.Second // count-rate averaging window
+
+	// directionTimeout is how long an axis must sit still before its
+	// direction of travel reads 0.
+	directionTimeout = 300 * time.Millisecond
+
+	// A loose A or B line makes the counter step back and forth. More
+	// reversals than this in a rate window is chatter, not a hand on the
+	// wheel; so are this many failed reads.
+	noisyReversals = 10
+	noisyErrors    = 3
+
+	// staleAfter is how long reads must have been failing before the
+	// axis's reading is reported stale.
+	staleAfter = time.Second
+
+	// The LS7366R needs f_f >= 4·f_QA and, in x4 mode, counts four edges per
+	// A cycle, so the filter clock frequency is also the max count rate (at
+	// filter divide 1, x4; x2 and x1 count half and a quarter as fast).
+	maxCountRate = gpclkHz
+)
+
+// encoderData holds every enabled axis in chip order; disabled axes are
+// left out.
 type encoderData struct {
-	X  encoderValues `json:"x"`
-	Xp encoderValues `json:"x'"`
-	Y  encoderValues `json:"y"`
-	Z  encoderValues `json:"z"`
+	Axes []encoderValues `json:"axes"`
+	Mode string          `json:"mode"` // modeAbs or modeInc
+	Hold bool            `json:"hold,omitempty"`
 }
 
 type encoderValues struct {
-	Count    int     `json:"count"`
-	RPM      float64 `json:"rpm"`
-	Distance float64 `json:"distance"` // distance in mm from zero
-	Label    string  `json:"label"`
+	Count      int64   `json:"count"`      // counts from the datum, or from the incremental zero in INC mode
+	RawCount   int64   `json:"rawCount"`   // accumulated hardware count
+	RPM        float64 `json:"rpm"`        // smoothed
+	RPMInstant float64 `json:"rpmInstant"` // last sample only, unsmoothed
+	Velocity   float64 `json:"velocity"`   // mm/s, from the smoothed RPM
+	Distance   float64 `json:"distance"`   // distance in mm from zero (datum or incremental zero)
+	Label      string  `json:"label"`
+
+	Clamped     bool     `json:"clamped,omitempty"`     // Distance is beyond MaxDistance
+	MaxDistance float64  `json:"maxDistance,omitempty"` // display clamp in mm (0 = off)
+	Unit        string   `json:"unit,omitempty"`        // display unit fixed for this axis; "" follows the page
+	AutoUnit    string   `json:"autoUnit"`              // mm or m, for the "auto" display unit
+	Version     uint64   `json:"version"`               // encoderVersion of the last change
+	Errors      int      `json:"errors"`                // failed counter reads (noisy or loose SPI wiring)
+	Homing      bool     `json:"homing,omitempty"`      // waiting for the index pulse
+	Diameter    bool     `json:"diameter,omitempty"`    // Distance is a diameter (2× travel)
+	Direction   int      `json:"direction"`             // +1 or -1 while moving, 0 when stopped
+	Status      string   `json:"status"`                // signalOK, signalStale, or signalNoisy
+	Angle       *float64 `json:"angle,omitempty"`       // rotary axes only: degrees from zero
+	Suspect     bool     `json:"suspect,omitempty"`     // a suspected slip was left out since the last zero
+	SlipCounts  int64    `json:"slipCounts,omitempty"`  // counts left out as suspected slips
+	TotalTravel float64  `json:"totalTravel"`           // mm moved either way since the last travel reset, for wear
+}
+
+// axisReading is /api/encoder/:axis: one axis's values plus its reading in
+// the requested unit.
+type axisReading struct {
+	encoderValues
+	Unit      string  `json:"unit"` // unit of Value: the axis's display unit, or its angle unit
+	Value     float64 `json:"value"`
+	Formatted string  `json:"formatted"` // as the card shows it
+}
+
+// axis returns the values for the axis labelled label, if it is enabled.
+func (d encoderData) axis(label string) (encoderValues, bool) {
+	for _, v := range d.Axes {
+		if v.Label == label {
+			return v, true
+		}
+	}
+	return encoderValues{}, false
+}
+
+// encoderRate is the per-axis count-rate diagnostic served by /api/encoder/rates.
+type encoderRate struct {
+	Label        string  `json:"label"`
+	CountsPerSec float64 `json:"countsPerSec"` // edges/s over the last rate window
+	MaxPerSec    float64 `json:"maxCountsPerSec"`
+	Utilization  float64 `json:"utilization"` // CountsPerSec / MaxPerSec
+	PeakPerSec   float64 `json:"peakCountsPerSec"`
+	PeakRPM      float64 `json:"peakRpm"`
+}
+
+// encoders holds one axis per configured counter, in chip order (by
+// default X, X', Y, Z on U1..U4).
+var encoders []*encoder
+
+// enabledEncoders returns the axes not disabled in the config, in chip order.
+func enabledEncoders() []*encoder {
+	var encs []*encoder
+	for _, enc := range encoders {
+		if !enc.disabled {
+			encs = append(encs, enc)
+		}
+	}
+	return encs
+}
+
+// Coordinate display modes, like a DRO's ABS/INC key.
+const (
+	modeAbs = "abs" // distance from the datum
+	modeInc = "inc" // distance from each axis's incremental zero
+)
+
+// incMode selects INC display. Zeroing and presets move the reference of
+// the mode in use, so INC zeros never disturb the datum.
+var incMode atomic.Bool
+
+func coordMode() string {
+	if incMode.Load() {
+		return modeInc
+	}
+	return modeAbs
+}
+
+// setCoordMode switches between ABS and INC display.
+func setCoordMode(mode string) error {
+	switch mode {
+	case modeAbs, modeInc:
+	default:
+		return fmt.Errorf("unknown mode %q (want %s or %s)", mode, modeAbs, modeInc)
+	}
+	if incMode.Swap(mode == modeInc) == (mode == modeInc) {
+		return nil
+	}
+	for _, enc := range encoders {
+		enc.mu.Lock()
+		enc.version = encoderVersion.Add(1) // every reading changes
+		enc.mu.Unlock()
+	}
+	return nil
+}
+
+// holdMode freezes every axis's reading at heldCount, like a DRO's hold key.
+// Counts keep accumulating underneath, so releasing it loses nothing.
+var holdMode atomic.Bool
+
+// setHold turns hold on or off, snapshotting each axis as it goes on.
+func setHold(on bool) {
+	if holdMode.Load() == on {
+		return
+	}
+	if !on {
+		holdMode.Store(false)
+	}
+	for _, enc := range encoders {
+		enc.mu.Lock()
+		if on {
+			enc.heldCount = enc.compensated()
+		}
+		enc.version = encoderVersion.Add(1)
+		enc.mu.Unlock()
+	}
+	holdMode.Store(on)
 }
 
-var encoders [4]*encoder // X=0, X'=1, Y=2, Z=3
+// encoderVersion is bumped whenever any axis's reading changes, so clients can
+// ask for just the axes that changed since the version they last saw.
+var encoderVersion atomic.Uint64
 
-// initEncoders sets up the four axes, LS7366R counters, and the poll loop.
-func initEncoders() error {
+// initEncoders sets up the configured axes, LS7366R counters, and the poll loop.
+func initEncoders(ctx context.Context) error {
 	now := time.Now()
-	encoders[0] = &encoder{label: "X", chip: 0, lastReadTime: now}
-	encoders[1] = &encoder{label: "X'", chip: 1, lastReadTime: now}
-	encoders[2] = &encoder{label: "Y", chip: 2, lastReadTime: now}
-	encoders[3] = &encoder{label: "Z", chip: 3, lastReadTime: now}
+	encoders = nil
+	for chip, label := range cfg().axisLabels() {
+		encoders = append(encoders, &encoder{label: label, chip: chip, lastReadTime: now, rateStart: now})
+	}
+	for _, enc := range encoders {
+		ac := cfg().axis(enc.label)
+		enc.disabled = !ac.enabled()
+		enc.maxDistance = ac.MaxDistance
+		enc.swapAB = ac.SwapAB
+		enc.index = ac.Index
+		enc.homeCount = ac.HomeCount
+		enc.filterDivide = ac.filterDivide()
+		enc.diameter = ac.Diameter
+		enc.rotary = ac.Rotary
+		enc.wrap = ac.Wrap
+		enc.unit = ac.Unit
+		enc.countsPerRev = ac.countsPerRev()
+		enc.quadrature = ac.quadrature()
+		enc.circumference = math.Pi * ac.wheelDiameter()
+		enc.calibration = ac.calibration()
+		enc.backlash = ac.BacklashCounts
+		enc.maxJump = ac.MaxJumpCounts
+		enc.totalTravel = savedTravel[enc.label]
+		enc.travelNoted = enc.totalTravel
+	}
+
+	src, err := openCounterSource()
+	if err != nil {
+		return err
+	}
+	counters = src
+	startWorker(func() { pollCountersForever(ctx) })
+	return nil
+}
+
+// update records a fresh hardware counter sample taken at now and recomputes RPM.
+func (enc *encoder) update(hw int32, now time.Time) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	// The LS7366R's count wraps at ±2³¹. Subtracting in int32 gives the true
+	// change across a wrap as long as the axis moves less than 2³¹ counts
+	// between samples, so counter keeps counting well past the register.
+	step := int64(hw - enc.hw)
+	enc.hw = hw
+	if enc.swapAB {
+		step = -step
+	}
+	count := enc.counter + step
+	if count != enc.counter {
+		enc.version = encoderVersion.Add(1)
+	}
+	enc.errorStreak = 0
+	enc.counter = count
+	delta := enc.counter - enc.lastReadCount
+	if enc.maxJump > 0 && abs(delta) > int64(enc.maxJump) {
+		slog.Warn("suspected slip, ignoring jump", "axis", enc.label, "counts", delta, "maxJumpCounts", enc.maxJump)
+		enc.slip += delta
+		enc.suspect = true
+		enc.version = encoderVersion.Add(1)
+		delta = 0
+	}
+	if delta != 0 && enc.direction != 0 && (delta > 0) != (enc.direction > 0) {
+		enc.rateReversals++
+	}
+	enc.takeUpLash(delta)
+	prevRPM := enc.rpm
+	elapsedSec := now.Sub(enc.lastReadTime).Seconds()
+	if elapsedSec > 0 {
+		moved := delta
+		if abs(moved) <= int64(cfg().RPMDeadbandCounts) {
+			moved = 0
+		}
+		enc.rpmInstant = (float64(moved) / enc.countsPerTurn()) * (60.0 / elapsedSec)
+		enc.peakRPM = max(enc.peakRPM, math.Abs(enc.rpmInstant))
+		alpha := cfg().rpmAlpha()
+		enc.rpm += alpha * (enc.rpmInstant - enc.rpm)
+		if enc.rpmInstant == 0 && math.Abs(enc.rpm) < 0.05 {
+			enc.rpm = 0 // settle on exactly zero instead of decaying forever
+		}
+		if enc.rpm != prevRPM {
+			enc.version = encoderVersion.Add(1) // live clients see the RPM settle
+		}
+		travel := enc.travel
+		if moved != 0 {
+			travel, enc.movedAt = 1, now
+			if moved < 0 {
+				travel = -1
+			}
+		} else if now.Sub(enc.movedAt) >= directionTimeout {
+			travel = 0
+		}
+		if travel != enc.travel {
+			enc.travel = travel
+			enc.version = encoderVersion.Add(1)
+		}
+	}
+	enc.lastReadCount = enc.counter
+	enc.lastReadTime = now
+
+	distance := enc.countsToMM(enc.position())
+	enc.autoUnit = nextAutoUnit(enc.autoUnit, distance, cfg().autoUnitHysteresis())
+
+	if enc.maxDistance > 0 {
+		clamped := math.Abs(distance) > enc.maxDistance
+		if clamped && !enc.clamped {
+			slog.Warn("distance beyond limit, clamping display",
+				"axis", enc.label, "distanceMm", distance, "maxDistanceMm", enc.maxDistance)
+		}
+		enc.clamped = clamped
+	}
+
+	enc.rateCounts += abs(delta)
+	enc.totalTravel += enc.countsToMM(abs(delta))
+	if enc.totalTravel-enc.travelNoted >= travelSaveStepMm {
+		enc.travelNoted = enc.totalTravel
+		notePointsChanged() // so a crash loses at most one step of the odometer
+	}
+	if window := now.Sub(enc.rateStart); window >= rateWindow {
+		enc.countRate = float64(enc.rateCounts) / window.Seconds()
+		enc.peakCountRate = max(enc.peakCountRate, enc.countRate)
+		noisy := enc.rateReversals > noisyReversals || enc.rateErrors >= noisyErrors
+		if noisy != enc.noisy {
+			enc.noisy = noisy
+			enc.version = encoderVersion.Add(1)
+			if noisy {
+				slog.Warn("encoder signal noisy", "axis", enc.label, "reversals", enc.rateReversals, "readErrors", enc.rateErrors)
+			}
+		}
+		enc.rateCounts = 0
+		enc.rateReversals = 0
+		enc.rateErrors = 0
+		enc.rateStart = now
+	}
+}
 
-	if err := initCounters(); err != nil {
+// home arms homing: the next index pulse sets the raw count to the axis's
+// homeCount and drops any datum, giving the same coordinates after every
+// power cycle.
+func (enc *encoder) home() error {
+	enc.mu.RLock()
+	index, hw := enc.index, int32(enc.homeCount)
+	if enc.swapAB {
+		hw = -hw
+	}
+	enc.mu.RUnlock()
+	if !index {
+		return fmt.Errorf("%s has no index input (set axes.%s.index in the config)", enc.label, enc.label)
+	}
+	countersMu.Lock()
+	if counters == nil {
+		countersMu.Unlock()
+		return fmt.Errorf("counter bank not open")
+	}
+	err := counters.armIndex(enc.chip, hw)
+	countersMu.Unlock()
+	if err != nil {
 		return err
 	}
-	go pollCountersForever()
+	enc.mu.Lock()
+	enc.homing = true
+	enc.version = encoderVersion.Add(1)
+	enc.mu.Unlock()
+	slog.Info("homing, waiting for index pulse", "axis", enc.label)
 	return nil
 }
 
+func (enc *encoder) isHoming() bool {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.homing
+}
+
+// homed takes the first sample after the index pulse loaded the counter. The
+// jump to homeCount is not motion, so it doesn't count towards RPM.
+func (enc *encoder) homed(hw int32, now time.Time) {
+	enc.mu.Lock()
+	enc.hw = hw
+	count := int64(hw)
+	if enc.swapAB {
+		count = -count
+	}
+	enc.counter, enc.lastReadCount, enc.lastReadTime = count, count, now
+	enc.offset = 0
+	enc.incOffset = count
+	enc.lash, enc.lashLeft, enc.direction = 0, 0, 0
+	enc.slip, enc.suspect = 0, false
+	enc.homing = false
+	enc.errorStreak = 0
+	enc.version = encoderVersion.Add(1)
+	enc.mu.Unlock()
+	probePath.clear()
+	slog.Info("homed on index", "axis", enc.label, "count", count)
+}
+
+// readFailed counts a failed counter read. The encoder keeps its last good
+// sample; the bumped version pushes the new error count to live clients.
+func (enc *encoder) readFailed() {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	enc.readErrors++
+	enc.errorStreak++
+	enc.rateErrors++
+	enc.version = encoderVersion.Add(1)
+}
+
+// Signal statuses reported per axis.
+const (
+	signalOK    = "ok"
+	signalStale = "stale" // reads have been failing for staleAfter: the reading is old
+	signalNoisy = "noisy" // the count chatters or reads fail now and then
+)
+
+// signal classifies the axis's encoder signal. Callers hold enc.mu.
+func (enc *encoder) signal(now time.Time) string {
+	switch {
+	case enc.errorStreak > 0 && now.Sub(enc.lastReadTime) >= staleAfter:
+		return signalStale
+	case enc.noisy:
+		return signalNoisy
+	}
+	return signalOK
+}
+
+// zeroEncoderCounts moves every axis's datum (or incremental zero, in INC
+// mode) to its current position.
 func zeroEncoderCounts() {
 	for _, enc := range encoders {
-		enc.mu.Lock()
-		enc.counter = 0
-		enc.lastReadCount = 0
-		enc.mu.Unlock()
+		enc.preset(0)
+	}
+}
+
+// takeUpLash runs a move of delta counts through the backlash model. After a
+// reversal the first backlash counts only take up the play, so the display
+// holds still; a reversal part way through takes up just what was crossed.
+// Callers hold enc.mu.
+func (enc *encoder) takeUpLash(delta int64) {
+	if delta == 0 {
+		return
+	}
+	dir := 1
+	if delta < 0 {
+		dir = -1
+	}
+	if enc.direction != 0 && dir != enc.direction {
+		enc.lashLeft = int64(enc.backlash) - enc.lashLeft
+	}
+	enc.direction = dir
+	taken := min(abs(delta), enc.lashLeft)
+	enc.lashLeft -= taken
+	enc.lash += int64(dir) * taken
+}
+
+// compensated is the hardware count less the play taken up. Callers hold enc.mu.
+func (enc *encoder) compensated() int64 {
+	return enc.counter - enc.lash - enc.slip
+}
+
+// shownCount is the compensated count the display and captures use: frozen
+// while hold is on. Callers hold enc.mu.
+func (enc *encoder) shownCount() int64 {
+	if holdMode.Load() {
+		return enc.heldCount
+	}
+	return enc.compensated()
+}
+
+// position is the count relative to the datum. Callers hold enc.mu.
+func (enc *encoder) position() int64 {
+	return enc.shownCount() - enc.offset
+}
+
+// displayPosition is the count relative to the reference of the current
+// mode. Callers hold enc.mu.
+func (enc *encoder) displayPosition() int64 {
+	if incMode.Load() {
+		return enc.shownCount() - enc.incOffset
+	}
+	return enc.position()
+}
+
+// absoluteMM is the distance from the datum in mm, whatever the display mode.
+func (enc *encoder) absoluteMM() float64 {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.countsToMM(enc.position())
+}
+
+// liveMM is absoluteMM as if hold were off: where the axis really is.
+func (enc *encoder) liveMM() float64 {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.countsToMM(enc.compensated() - enc.offset)
+}
+
+func abs[T int | int64](n T) T {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// countsToMM converts a count to calibrated travel in mm. A float64 holds
+// counts exactly up to 2⁵³, far beyond any real travel. Callers hold enc.mu.
+func (enc *encoder) countsToMM(count int64) float64 {
+	return (float64(count) / enc.countsPerTurn()) * enc.mmPerRev()
+}
+
+// countsPerTurn is the counts per wheel revolution actually decoded:
+// countsPerRev assumes x4, and x2 or x1 counting gives a half or a quarter.
+func (enc *encoder) countsPerTurn() float64 {
+	return enc.countsPerRev * float64(enc.quadrature) / 4
+}
+
+// mmPerRev is the calibrated travel for one wheel revolution.
+func (enc *encoder) mmPerRev() float64 {
+	return enc.circumference * enc.calibration
+}
+
+// countsToDegrees converts a count to an angle, folded into [0, 360) when
+// the axis wraps.
+func (enc *encoder) countsToDegrees(count int64) float64 {
+	if !enc.wrap {
+		return float64(count) / enc.countsPerTurn() * 360
+	}
+	// Fold whole turns off the count first, so a spindle that has turned
+	// for hours keeps its fractional angle.
+	rem := math.Mod(float64(count), enc.countsPerTurn())
+	if rem < 0 {
+		rem += enc.countsPerTurn()
+	}
+	return rem / enc.countsPerTurn() * 360
+}
+
+// preset moves the datum so this axis reads distanceMM at its current
+// position, e.g. after touching off a gauge block; preset(0) zeros the axis.
+// Only the offset changes: the hardware count keeps accumulating untouched.
+// In INC mode the incremental zero moves instead. In diameter mode
+// distanceMM is a diameter.
+func (enc *encoder) preset(distanceMM float64) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if enc.diameter {
+		distanceMM /= 2
+	}
+	enc.presetCount(int64(math.Round(distanceMM / enc.mmPerRev() * enc.countsPerTurn())))
+}
+
+// presetAngle is preset for a rotary axis: the axis reads deg degrees.
+func (enc *encoder) presetAngle(deg float64) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	enc.presetCount(int64(math.Round(deg / 360 * enc.countsPerTurn())))
+}
+
+// presetCount makes the axis read target counts and clears the suspect
+// flag. Caller holds enc.mu.
+func (enc *encoder) presetCount(target int64) {
+	if enc.displayPosition() != target || enc.suspect {
+		enc.version = encoderVersion.Add(1)
+	}
+	enc.suspect = false // the reading is set against a reference again
+	if incMode.Load() {
+		enc.incOffset = enc.shownCount() - target
+		return
 	}
+	if offset := enc.shownCount() - target; offset != enc.offset {
+		enc.offset = offset
+		probePath.clear() // the trace is in the old absolute frame
+	}
+}
+
+// shiftDatum moves the datum mm along the axis, so the spot that read mm
+// reads 0, and returns the shift made, which is a whole number of counts.
+// The incremental zero is left alone.
+func (enc *encoder) shiftDatum(mm float64) float64 {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	counts := int64(math.Round(mm / enc.mmPerRev() * enc.countsPerTurn()))
+	if counts != 0 {
+		enc.offset += counts
+		enc.version = encoderVersion.Add(1)
+		probePath.clear()
+	}
+	return enc.countsToMM(counts)
+}
+
+// restoreDatum sets the absolute and incremental zeros to saved counter
+// values, e.g. from a session archive made on this device.
+func (enc *encoder) restoreDatum(offset, incOffset int64) {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if offset != enc.offset || incOffset != enc.incOffset {
+		enc.version = encoderVersion.Add(1)
+	}
+	if offset != enc.offset {
+		probePath.clear()
+	}
+	enc.offset, enc.incOffset = offset, incOffset
 }
 
 func getEncoderData() encoderData {
-	var data encoderData
-	for i, enc := range encoders {
+	data := encoderData{Mode: coordMode(), Hold: holdMode.Load()}
+	for _, enc := range enabledEncoders() {
 		enc.mu.RLock()
-		count := enc.counter
+		count := enc.displayPosition()
+		rawCount := enc.counter
 		rpm := enc.rpm
+		rpmInstant := enc.rpmInstant
 		label := enc.label
+		maxDistance := enc.maxDistance
+		unit := enc.unit
+		autoUnit := enc.autoUnit
+		version := enc.version
+		readErrors := enc.readErrors
+		homing := enc.homing
+		diameter := enc.diameter
+		direction := enc.travel
+		suspect, slip := enc.suspect, enc.slip
+		totalTravel := enc.totalTravel
+		signal := enc.signal(time.Now())
+		distance := enc.countsToMM(count)
+		if diameter {
+			distance *= 2
+		}
+		velocity := rpm / 60 * enc.mmPerRev()
+		var angle *float64
+		if enc.rotary {
+			deg := enc.countsToDegrees(count)
+			angle = &deg
+		}
 		enc.mu.RUnlock()
-
-		distance := (float64(count) / countsPerRev) * wheelCircumference
+		clamped := maxDistance > 0 && math.Abs(distance) > maxDistance
 
 		values := encoderValues{
-			Count:    count,
-			RPM:      rpm,
-			Distance: distance,
-			Label:    label,
+			Count:       count,
+			RawCount:    rawCount,
+			RPM:         rpm,
+			RPMInstant:  rpmInstant,
+			Velocity:    velocity,
+			Distance:    distance,
+			Label:       label,
+			Clamped:     clamped,
+			MaxDistance: maxDistance,
+			Unit:        unit,
+			AutoUnit:    nextAutoUnit(autoUnit, distance, cfg().autoUnitHysteresis()),
+			Version:     version,
+			Errors:      readErrors,
+			Homing:      homing,
+			Diameter:    diameter,
+			Direction:   direction,
+			Status:      signal,
+			Angle:       angle,
+			Suspect:     suspect,
+			SlipCounts:  slip,
+			TotalTravel: totalTravel,
 		}
 
-		switch i {
-		case 0:
-			data.X = values
-		case 1:
-			data.Xp = values
-		case 2:
-			data.Y = values
-		case 3:
-			data.Z = values
-		}
+		data.Axes = append(data.Axes, values)
 	}
 	return data
 }
+
+func getEncoderRates() []encoderRate {
+	rates := make([]encoderRate, 0, len(encoders))
+	for _, enc := range enabledEncoders() {
+		enc.mu.RLock()
+		r := encoderRate{
+			Label:        enc.label,
+			CountsPerSec: enc.countRate,
+			MaxPerSec:    maxCountRate / float64(enc.filterDivide) * float64(enc.quadrature) / 4,
+			PeakPerSec:   enc.peakCountRate,
+			PeakRPM:      enc.peakRPM,
+		}
+		enc.mu.RUnlock()
+		r.Utilization = r.CountsPerSec / r.MaxPerSec
+		rates = append(rates, r)
+	}
+	return rates
+}
+
+// encoderByAxis finds an enabled encoder by label, case-insensitively. "xp" is
+// accepted for X' since quotes are awkward in URLs.
+func encoderByAxis(axis string) (*encoder, bool) {
+	if strings.EqualFold(axis, "xp") {
+		axis = "X'"
+	}
+	for _, enc := range enabledEncoders() {
+		if strings.EqualFold(enc.label, axis) {
+			return enc, true
+		}
+	}
+	return nil, false
+}
+
+// diagnosticResets zero one named per-axis diagnostic. Each runs with the
+// encoder's lock held. Position is deliberately not in here: zeroing stays
+// an explicit, separate operation.
+var diagnosticResets = map[string]func(enc *encoder){
+	"peaks": func(enc *encoder) {
+		enc.peakRPM = 0
+		enc.peakCountRate = 0
+	},
+	"suspect": func(enc *encoder) {
+		if enc.suspect {
+			enc.version = encoderVersion.Add(1) // the card shows the flag
+		}
+		enc.suspect = false
+	},
+	"travel": func(enc *encoder) {
+		enc.totalTravel, enc.travelNoted = 0, 0
+		enc.version = encoderVersion.Add(1)
+		notePointsChanged()
+	},
+	"errors": func(enc *encoder) {
+		if enc.readErrors != 0 {
+			enc.version = encoderVersion.Add(1) // the card shows the count
+		}
+		enc.readErrors = 0
+	},
+}
+
+// resetDiagnostic zeros the diagnostic named what on the given axis, or on
+// every axis when axis is "" or "all".
+func resetDiagnostic(what, axis string) error {
+	reset, ok := diagnosticResets[what]
+	if !ok {
+		names := make([]string, 0, len(diagnosticResets))
+		for name := range diagnosticResets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown diagnostic %q (want one of %s)", what, strings.Join(names, ", "))
+	}
+	targets := enabledEncoders()
+	if axis != "" && axis != "all" {
+		enc, ok := encoderByAxis(axis)
+		if !ok {
+			return fmt.Errorf("unknown axis %q", axis)
+		}
+		targets = []*encoder{enc}
+	}
+	for _, enc := range targets {
+		enc.mu.Lock()
+		reset(enc)
+		enc.mu.Unlock()
+	}
+	return nil
+}
+
+// setUnit fixes the axis's display unit, or with "" makes it follow the
+// page's unit again, and saves it to the config file.
+func (enc *encoder) setUnit(unit string) error {
+	if unit != "" && validUnit(unit) != unit {
+		return fmt.Errorf("unknown unit %q", unit)
+	}
+	enc.mu.Lock()
+	if enc.unit != unit {
+		enc.version = encoderVersion.Add(1)
+	}
+	enc.unit = unit
+	enc.mu.Unlock()
+	return updateConfig(func(c *config) {
+		ac := c.Axes[enc.label]
+		ac.Unit = unit
+		c.Axes[enc.label] = ac
+	})
+}
+
+func (enc *encoder) displayUnit() string {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.unit
+}
+
+// setDiameter turns diameter mode on or off for the axis and saves it to
+// the config file.
+func (enc *encoder) setDiameter(on bool) error {
+	enc.mu.Lock()
+	if enc.diameter != on {
+		enc.version = encoderVersion.Add(1)
+	}
+	enc.diameter = on
+	enc.mu.Unlock()
+	return updateConfig(func(c *config) {
+		ac := c.Axes[enc.label]
+		ac.Diameter = on
+		c.Axes[enc.label] = ac
+	})
+}
+
+// setSwapAB turns A/B swapping on or off for the axis and saves it to the
+// config file. Every count the encoder holds is negated with it, so the axis
+// simply reads the other way round: the next sample isn't a jump, and the
+// datum, backlash play and direction all stay consistent.
+func (enc *encoder) setSwapAB(on bool) error {
+	enc.mu.Lock()
+	if enc.swapAB != on {
+		enc.swapAB = on
+		enc.counter, enc.lastReadCount, enc.calStart = -enc.counter, -enc.lastReadCount, -enc.calStart
+		enc.offset, enc.incOffset, enc.lash = -enc.offset, -enc.incOffset, -enc.lash
+		enc.slip, enc.heldCount = -enc.slip, -enc.heldCount
+		enc.direction, enc.travel = -enc.direction, -enc.travel
+		enc.rpm, enc.rpmInstant = -enc.rpm, -enc.rpmInstant
+		enc.version = encoderVersion.Add(1)
+		probePath.clear()
+	}
+	enc.mu.Unlock()
+	return updateConfig(func(c *config) {
+		ac := c.Axes[enc.label]
+		ac.SwapAB = on
+		c.Axes[enc.label] = ac
+	})
+}
+
+func (enc *encoder) isSwapAB() bool {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.swapAB
+}
+
+func (enc *encoder) isDiameter() bool {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.diameter
+}
+
+func (enc *encoder) isRotary() bool {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return enc.rotary
+}
+
+// maxBacklashCounts caps the backlash setting; more play than this is a
+// slipping wheel, not lash.
+const maxBacklashCounts = 10000
+
+// setBacklash sets the play taken up after a reversal, in counts, and saves
+// it to the config file. Play being taken up right now is forgotten.
+func (enc *encoder) setBacklash(counts int) error {
+	if counts < 0 || counts > maxBacklashCounts {
+		return fmt.Errorf("backlash %d counts out of range 0..%d", counts, maxBacklashCounts)
+	}
+	enc.mu.Lock()
+	enc.backlash = counts
+	enc.lashLeft = 0
+	enc.mu.Unlock()
+	return updateConfig(func(c *config) {
+		ac := c.Axes[enc.label]
+		ac.BacklashCounts = counts
+		c.Axes[enc.label] = ac
+	})
+}
+
+// backlashCounts converts a backlash in mm to counts on this axis.
+func (enc *encoder) backlashCounts(mm float64) int {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return int(math.Round(mm / enc.mmPerRev() * enc.countsPerTurn()))
+}
+
+// Bounds on an axis's calibration factor. A wheel off by more than this is
+// misconfigured (wrong diameter or counts/rev), not slightly worn.
+const (
+	minCalibration = 0.5
+	maxCalibration = 2.0
+)
+
+func validCalibration(f float64) bool {
+	return f >= minCalibration && f <= maxCalibration
+}
+
+// calibrate corrects the axis's scale from a reference length: measured is
+// what the axis read over a known length of reference (any one unit). The
+// new factor compounds with the current one, so calibrating again refines
+// it. factor > 0 sets the factor outright instead, e.g. 1 to clear it.
+// The factor is saved to the config file.
+func (enc *encoder) calibrate(reference, measured, factor float64) error {
+	enc.mu.Lock()
+	if factor == 0 {
+		if !(reference > 0 && measured > 0) {
+			enc.mu.Unlock()
+			return fmt.Errorf("reference and measured must be positive")
+		}
+		factor = enc.calibration * reference / measured
+	}
+	if !validCalibration(factor) {
+		enc.mu.Unlock()
+		return fmt.Errorf("calibration factor %.4f out of range %v..%v", factor, minCalibration, maxCalibration)
+	}
+	if factor != enc.calibration {
+		enc.version = encoderVersion.Add(1)
+		probePath.clear()
+	}
+	enc.calibration = factor
+	enc.mu.Unlock()
+	return updateConfig(func(c *config) {
+		ac := c.Axes[enc.label]
+		ac.Calibration = factor
+		c.Axes[enc.label] = ac
+	})
+}
+
+// minCalibrationCounts is the least travel a guided calibration accepts; a
+// shorter move can't pin the scale down.
+const minCalibrationCounts = 100
+
+// calibrationResult is the outcome of a guided calibration.
+type calibrationResult struct {
+	Label             string  `json:"label"`
+	Counts            int64   `json:"counts"`            // counts over the reference move
+	CountsPerMMBefore float64 `json:"countsPerMmBefore"` // from the old scale
+	CountsPerMMAfter  float64 `json:"countsPerMmAfter"`  // measured
+	FactorBefore      float64 `json:"calibrationBefore"`
+	FactorAfter       float64 `json:"calibrationAfter"`
+}
+
+// startCalibration begins a guided calibration: it notes the hardware
+// count, so the display and datum are left alone.
+func (enc *encoder) startCalibration() error {
+	enc.mu.Lock()
+	defer enc.mu.Unlock()
+	if enc.rotary {
+		return fmt.Errorf("axis %s is rotary", enc.label)
+	}
+	enc.calStart = enc.counter
+	enc.calibrating = true
+	return nil
+}
+
+// finishCalibration ends a guided calibration after the axis moved
+// distanceMM (wheel travel, not a diameter). It sets the calibration factor
+// so the counts over the move read exactly distanceMM.
+func (enc *encoder) finishCalibration(distanceMM float64) (calibrationResult, error) {
+	enc.mu.Lock()
+	if !enc.calibrating {
+		enc.mu.Unlock()
+		return calibrationResult{}, fmt.Errorf("no calibration started on axis %s", enc.label)
+	}
+	counts := abs(enc.counter - enc.calStart)
+	if counts < minCalibrationCounts {
+		enc.mu.Unlock()
+		return calibrationResult{}, fmt.Errorf("moved only %d counts; move at least %d", counts, minCalibrationCounts)
+	}
+	if !(distanceMM > 0) {
+		enc.mu.Unlock()
+		return calibrationResult{}, fmt.Errorf("distance must be positive")
+	}
+	r := calibrationResult{
+		Label:             enc.label,
+		Counts:            counts,
+		CountsPerMMBefore: enc.countsPerTurn() / enc.mmPerRev(),
+		CountsPerMMAfter:  float64(counts) / distanceMM,
+		FactorBefore:      enc.calibration,
+	}
+	r.FactorAfter = enc.countsPerTurn() / enc.circumference / r.CountsPerMMAfter
+	if !validCalibration(r.FactorAfter) {
+		// Keep the calibration open so the distance can be re-entered.
+		enc.mu.Unlock()
+		return calibrationResult{}, fmt.Errorf("calibration factor %.4f out of range %v..%v: check countsPerRev and wheelDiameterMm", r.FactorAfter, minCalibration, maxCalibration)
+	}
+	enc.calibrating = false
+	enc.mu.Unlock()
+	return r, enc.calibrate(0, 0, r.FactorAfter)
+}
+
+// encoderConfig is an axis's scaling as served by /api/encoder/config.
+type encoderConfig struct {
+	Label         string  `json:"label"`
+	Enabled       bool    `json:"enabled"`
+	CountsPerRev  float64 `json:"countsPerRev"`
+	WheelDiameter float64 `json:"wheelDiameterMm"`
+	Circumference float64 `json:"wheelCircumferenceMm"`
+	Calibration   float64 `json:"calibration"`
+	Backlash      int     `json:"backlashCounts"`
+	BacklashMM    float64 `json:"backlashMm"`
+	MMPerCount    float64 `json:"mmPerCount"` // including calibration
+	MaxDistance   float64 `json:"maxDistance"`
+	SwapAB        bool    `json:"swapAB"`
+	Index         bool    `json:"index"`
+	HomeCount     int     `json:"homeCount"`
+	FilterDivide  int     `json:"filterDivide"`
+	Quadrature    int     `json:"quadrature"` // edges counted per A/B cycle: 1, 2, or 4
+	FilterClockHz float64 `json:"filterClockHz"`
+	Diameter      bool    `json:"diameter"`
+	Rotary        bool    `json:"rotary"`
+	Wrap          bool    `json:"wrap"`
+	Unit          string  `json:"unit"` // "" follows the page's unit
+}
+
+func (enc *encoder) config() encoderConfig {
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	return encoderConfig{
+		Label:         enc.label,
+		Enabled:       !enc.disabled,
+		CountsPerRev:  enc.countsPerRev,
+		WheelDiameter: enc.circumference / math.Pi,
+		Circumference: enc.circumference,
+		Calibration:   enc.calibration,
+		Backlash:      enc.backlash,
+		BacklashMM:    enc.countsToMM(int64(enc.backlash)),
+		MMPerCount:    enc.mmPerRev() / enc.countsPerTurn(),
+		MaxDistance:   enc.maxDistance,
+		SwapAB:        enc.swapAB,
+		Index:         enc.index,
+		HomeCount:     enc.homeCount,
+		FilterDivide:  enc.filterDivide,
+		Quadrature:    enc.quadrature,
+		FilterClockHz: gpclkHz / float64(enc.filterDivide),
+		Diameter:      enc.diameter,
+		Rotary:        enc.rotary,
+		Wrap:          enc.wrap,
+		Unit:          enc.unit,
+	}
+}
+
+func getEncoderConfigs() []encoderConfig {
+	configs := make([]encoderConfig, 0, len(encoders))
+	for _, enc := range encoders {
+		configs = append(configs, enc.config())
+	}
+	return configs
+}
+
+// setScale changes an axis's counts-per-rev and/or wheel diameter (0 leaves a
+// value as is). Distances are derived from the count on every read, so the
+// display follows immediately. The change is saved to the config file.
+func (enc *encoder) setScale(countsPerRev, wheelDiameter float64) error {
+	if countsPerRev < 0 || wheelDiameter < 0 || math.IsNaN(countsPerRev) || math.IsNaN(wheelDiameter) {
+		return fmt.Errorf("countsPerRev and wheelDiameterMm must be positive")
+	}
+	enc.mu.Lock()
+	if countsPerRev > 0 {
+		enc.countsPerRev = countsPerRev
+	}
+	if wheelDiameter > 0 {
+		enc.circumference = math.Pi * wheelDiameter
+	}
+	countsPerRev, wheelDiameter = enc.countsPerRev, enc.circumference/math.Pi
+	enc.mu.Unlock()
+	probePath.clear()
+	return updateConfig(func(c *config) {
+		ac := c.Axes[enc.label]
+		ac.CountsPerRev = countsPerRev
+		ac.WheelDiameterMm = wheelDiameter
+		c.Axes[enc.label] = ac
+	})
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchEncoders gives the benchmark four fresh axes at the default scale,
+// restored afterwards, and returns X.
+func benchEncoders(b *testing.B) *encoder {
+	b.Helper()
+	saved := encoders
+	now := time.Now()
+	encoders = nil
+	for i, label := range []string{"X", "X'", "Y", "Z"} {
+		encoders = append(encoders, &encoder{
+			label:         label,
+			chip:          i,
+			countsPerRev:  defaultCountsPerRev,
+			quadrature:    4,
+			circumference: defaultWheelCircumference,
+			calibration:   1,
+			lastReadTime:  now,
+			rateStart:     now,
+		})
+	}
+	b.Cleanup(func() { encoders = saved })
+	return encoders[0]
+}
+
+// BenchmarkEncoderUpdate is the per-axis cost of one poll once the counts
+// are latched, which bounds how fast the counters can be polled.
+func BenchmarkEncoderUpdate(b *testing.B) {
+	enc := benchEncoders(b)
+	now := time.Now()
+	b.ResetTimer()
+	for i := range b.N {
+		enc.update(int32(i*16), now.Add(time.Duration(i)*time.Millisecond))
+	}
+}
+
+// BenchmarkEncoderUpdateContended is BenchmarkEncoderUpdate with live clients
+// reading the axes in parallel, as the poller sees it at a high push rate.
+func BenchmarkEncoderUpdateContended(b *testing.B) {
+	enc := benchEncoders(b)
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	for range 4 {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				getEncoderData()
+			}
+		}()
+	}
+	now := time.Now()
+	b.ResetTimer()
+	for i := range b.N {
+		enc.update(int32(i*16), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	b.StopTimer()
+	close(stop)
+	readers.Wait()
+}
+
+// newTestEncoder returns an X axis at the default scale (2400 counts per
+// 50 mm wheel revolution), as the only encoder, with hold off. The live
+// config is restored afterwards, as setters save to it.
+func newTestEncoder(t *testing.T) *encoder {
+	t.Helper()
+	now := time.Now()
+	enc := &encoder{
+		label:         "X",
+		countsPerRev:  defaultCountsPerRev,
+		quadrature:    4,
+		circumference: defaultWheelCircumference,
+		calibration:   1,
+		lastReadTime:  now,
+		rateStart:     now,
+	}
+	saved, savedCfg := encoders, cfg()
+	encoders = []*encoder{enc}
+	t.Cleanup(func() {
+		encoders = saved
+		liveConfig.Store(savedCfg)
+		holdMode.Store(false)
+	})
+	return enc
+}
+
+func TestSetSwapABWhileHeld(t *testing.T) {
+	enc := newTestEncoder(t)
+	now := time.Now()
+	enc.update(1000, now)
+	setHold(true)
+	enc.update(1500, now.Add(50*time.Millisecond))
+	if err := enc.setSwapAB(true); err != nil {
+		t.Fatal(err)
+	}
+	enc.mu.RLock()
+	defer enc.mu.RUnlock()
+	if got := enc.position(); got != -1000 {
+		t.Errorf("held position after swapping A/B = %d, want -1000", got)
+	}
+}
+
+// withTestPath gives the test its own probePath, holding one sample.
+func withTestPath(t *testing.T) {
+	t.Helper()
+	saved := probePath
+	probePath = newPathTrace(8)
+	probePath.add(point{x: 1, y: 2, z: 3})
+	t.Cleanup(func() { probePath = saved })
+}
+
+func TestDatumMoveClearsPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		move  func(enc *encoder)
+		clear bool
+	}{
+		{"preset", func(enc *encoder) { enc.preset(5) }, true},
+		{"preset to the same reading", func(enc *encoder) { enc.preset(0) }, false},
+		{"shift datum", func(enc *encoder) { enc.shiftDatum(5) }, true},
+		{"restore datum", func(enc *encoder) { enc.restoreDatum(100, 0) }, true},
+		{"restore incremental zero only", func(enc *encoder) { enc.restoreDatum(0, 100) }, false},
+		{"homed", func(enc *encoder) { enc.homed(0, time.Now()) }, true},
+		{"swap A/B", func(enc *encoder) { enc.setSwapAB(true) }, true},
+		{"calibrate", func(enc *encoder) { enc.calibrate(0, 0, 1.01) }, true},
+		{"scale", func(enc *encoder) { enc.setScale(1000, 0) }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := newTestEncoder(t)
+			withTestPath(t)
+			tt.move(enc)
+			if got := len(probePath.snapshot()) == 0; got != tt.clear {
+				t.Errorf("path cleared = %v, want %v", got, tt.clear)
+			}
+		})
+	}
+}
+
+func TestPathDropsSampleFromBeforeClear(t *testing.T) {
+	withTestPath(t)
+	gen := probePath.generation()
+	probePath.clear()
+	probePath.addSince(gen, point{x: 4})
+	if n := len(probePath.snapshot()); n != 0 {
+		t.Errorf("path holds %d samples, want the stale one dropped", n)
+	}
+	probePath.addSince(probePath.generation(), point{x: 5})
+	if n := len(probePath.snapshot()); n != 1 {
+		t.Errorf("path holds %d samples, want 1", n)
+	}
+}
+
+func TestUpdateAcrossRegisterWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		start int32
+		hw    []int32
+	}{
+		{"forward", 0x7FFFFFF0, []int32{0x7FFFFFF8, 0x7FFFFFFF, -0x80000000, -0x7FFFFFF8}},
+		{"backward", -0x7FFFFFF0, []int32{-0x7FFFFFF8, -0x80000000, 0x7FFFFFFF, 0x7FFFFFF8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := newTestEncoder(t)
+			// As if the axis had counted up to the register value.
+			enc.hw, enc.counter, enc.lastReadCount = tt.start, int64(tt.start), int64(tt.start)
+			now := time.Now()
+			prevHW, prevCount := tt.start, int64(tt.start)
+			var travel float64
+			for i, hw := range tt.hw {
+				now = now.Add(10 * time.Millisecond)
+				enc.update(hw, now)
+				step := int64(hw - prevHW) // the register's wrapped difference
+				if abs(step) > 8 {
+					t.Fatalf("sample %d: bad test step %d", i, step)
+				}
+				enc.mu.RLock()
+				count, pos, total := enc.counter, enc.position(), enc.totalTravel
+				enc.mu.RUnlock()
+				if count != prevCount+step {
+					t.Errorf("sample %d (hw %#x): counter = %d, want %d", i, hw, count, prevCount+step)
+				}
+				if pos != count {
+					t.Errorf("sample %d (hw %#x): position = %d, want %d", i, hw, pos, count)
+				}
+				travel += enc.countsToMM(abs(step))
+				if math.Abs(total-travel) > 1e-9 {
+					t.Errorf("sample %d (hw %#x): totalTravel = %v mm, want %v", i, hw, total, travel)
+				}
+				prevHW, prevCount = hw, count
+			}
+		})
+	}
+}
+
+func TestTakeUpLash(t *testing.T) {
+	tests := []struct {
+		name     string
+		backlash int
+		hw       []int32 // successive raw counts
+		want     []int64 // position after each
+	}{
+		{"reversal takes up the play first", 10, []int32{100, 95, 90, 85}, []int64{100, 100, 100, 95}},
+		{"move larger than the play", 10, []int32{100, 80}, []int64{100, 90}},
+		{"reversal inside the play", 10, []int32{100, 96, 100, 105}, []int64{100, 100, 100, 105}},
+		{"second reversal", 10, []int32{100, 80, 85, 95, 100}, []int64{100, 90, 90, 95, 100}},
+		{"no backlash", 0, []int32{100, 95}, []int64{100, 95}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := newTestEncoder(t)
+			enc.backlash = tt.backlash
+			now := time.Now()
+			for i, hw := range tt.hw {
+				now = now.Add(10 * time.Millisecond)
+				enc.update(hw, now)
+				enc.mu.RLock()
+				got := enc.position()
+				enc.mu.RUnlock()
+				if got != tt.want[i] {
+					t.Errorf("after raw count %d: position = %d, want %d", hw, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTravelWakesAutosave(t *testing.T) {
+	enc := newTestEncoder(t)
+	drain := func() bool {
+		select {
+		case <-pointsChanged:
+			return true
+		default:
+			return false
+		}
+	}
+	drain()
+	perMM := enc.countsPerTurn() / enc.mmPerRev()
+	now := time.Now()
+	hw := int32(0)
+	// Back and forth, so position stays small while the odometer runs.
+	move := func(mm float64) {
+		now = now.Add(10 * time.Millisecond)
+		hw += int32(math.Round(mm * perMM))
+		enc.update(hw, now)
+	}
+	move(40)
+	move(-40)
+	if drain() {
+		t.Fatal("autosave woken after 80 mm")
+	}
+	move(30) // 110 mm
+	if !drain() {
+		t.Fatal("autosave not woken after 110 mm")
+	}
+	move(-50) // 160 mm, 50 since the last wake
+	if drain() {
+		t.Fatal("autosave woken again 50 mm later")
+	}
+	move(60) // 220 mm
+	if !drain() {
+		t.Fatal("autosave not woken 110 mm later")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// gpioEvent is one recorded line change: when it happened (from the start
+// of the recording), the GPIO, and the level after it. The file format is
+// one "seconds pin level" line per event; blank and # lines are skipped.
+//
+// Only the foot switch is a GPIO line: the LS7366R decodes A/B in hardware,
+// so encoder edges never reach the Pi and can't be recorded.
+type gpioEvent struct {
+	at    time.Duration
+	pin   int
+	level int
+}
+
+// eventRecorder appends live GPIO events to recordEventsPath.
+var eventRecorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	start time.Time
+}
+
+// startEventRecorder starts writing GPIO events to path, replacing any
+// earlier recording. It does nothing when path is "".
+func startEventRecorder(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("record events: %w", err)
+	}
+	eventRecorder.mu.Lock()
+	defer eventRecorder.mu.Unlock()
+	eventRecorder.f, eventRecorder.w, eventRecorder.start = f, bufio.NewWriter(f), time.Now()
+	fmt.Fprintf(eventRecorder.w, "# closinuf %s GPIO events from %s: seconds pin level\n", version, eventRecorder.start.Format(time.RFC3339))
+	slog.Info("recording GPIO events", "path", path)
+	return nil
+}
+
+// recordEvent logs a line change seen at t, when recording.
+func recordEvent(pin, level int, t time.Time) {
+	eventRecorder.mu.Lock()
+	defer eventRecorder.mu.Unlock()
+	if eventRecorder.w == nil {
+		return
+	}
+	fmt.Fprintf(eventRecorder.w, "%.6f %d %d\n", t.Sub(eventRecorder.start).Seconds(), pin, level)
+	// Flush each event so a crash keeps the sequence that led to it.
+	if err := eventRecorder.w.Flush(); err != nil {
+		slog.Error("record events", "err", err)
+	}
+}
+
+func stopEventRecorder() {
+	eventRecorder.mu.Lock()
+	defer eventRecorder.mu.Unlock()
+	if eventRecorder.f == nil {
+		return
+	}
+	eventRecorder.w.Flush()
+	eventRecorder.f.Close()
+	eventRecorder.f, eventRecorder.w = nil, nil
+}
+
+// readEvents parses a recording. Events must be in time order.
+func readEvents(r io.Reader) ([]gpioEvent, error) {
+	var events []gpioEvent
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: want \"seconds pin level\", got %q", n, line)
+		}
+		sec, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || sec < 0 {
+			return nil, fmt.Errorf("line %d: bad time %q", n, fields[0])
+		}
+		pin, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad pin %q", n, fields[1])
+		}
+		level, err := strconv.Atoi(fields[2])
+		if err != nil || (level != 0 && level != 1) {
+			return nil, fmt.Errorf("line %d: level %q is not 0 or 1", n, fields[2])
+		}
+		e := gpioEvent{at: time.Duration(sec * float64(time.Second)), pin: pin, level: level}
+		if len(events) > 0 && e.at < events[len(events)-1].at {
+			return nil, fmt.Errorf("line %d: time goes backwards", n)
+		}
+		events = append(events, e)
+	}
+	return events, sc.Err()
+}
+
+// replaying is set while a replay runs; replays don't overlap.
+var replaying atomic.Bool
+
+// replayEvents feeds the foot-switch events of a recording through the
+// switch logic with their original spacing, as if the switch were wired up.
+// Events on other pins are skipped. It returns when the recording ends or
+// ctx is cancelled.
+func replayEvents(ctx context.Context, events []gpioEvent) {
+	done, stop := startReplay(events)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		stop()
+	}
+}
+
+// startReplay schedules replayEvents' work on clk, each event at its offset
+// from now. done is closed after the last event; stop cancels the rest.
+func startReplay(events []gpioEvent) (done <-chan struct{}, stop func()) {
+	btnEventMu.Lock()
+	resetPointButton()
+	level := 1 - pointButton.active // released
+	btnRead = func() (int, error) {
+		btnEventMu.Lock()
+		defer btnEventMu.Unlock()
+		return level, nil
+	}
+	btnEventMu.Unlock()
+
+	var (
+		mu      sync.Mutex
+		pending timer
+		stopped bool
+	)
+	finished := make(chan struct{})
+	pin, start := cfg().Pins.PointButton, clk.now()
+	var schedule func(i int)
+	schedule = func(i int) {
+		for i < len(events) && events[i].pin != pin {
+			i++
+		}
+		if i == len(events) {
+			close(finished)
+			return
+		}
+		e := events[i]
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		pending = clk.afterFunc(start.Add(e.at).Sub(clk.now()), func() {
+			mu.Lock()
+			cancelled := stopped
+			mu.Unlock()
+			if cancelled {
+				return
+			}
+			btnEventMu.Lock()
+			level = e.level
+			btnEventMu.Unlock()
+			onPointButtonLevel(e.level)
+			schedule(i + 1)
+		})
+	}
+	schedule(0)
+	return finished, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		if pending != nil {
+			pending.Stop()
+		}
+	}
+}
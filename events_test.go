@@ -0,0 +1,203 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is simulated time: afterFunc calls run, in deadline order, when
+// run advances the clock to them.
+type fakeClock struct {
+	mu     sync.Mutex
+	t      time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c    *fakeClock
+	at   time.Time
+	f    func()
+	done bool // fired or stopped
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, at: c.t.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	pending := !t.done
+	t.done = true
+	return pending
+}
+
+// run fires pending timers, earliest first, until there are none.
+func (c *fakeClock) run() {
+	c.runUntil(time.Time{})
+}
+
+// runUntil fires pending timers due by end, earliest first, and moves the
+// clock to end. A zero end runs until no timers are left.
+func (c *fakeClock) runUntil(end time.Time) {
+	for {
+		c.mu.Lock()
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.done && (end.IsZero() || !t.at.After(end)) && (next == nil || t.at.Before(next.at)) {
+				next = t
+			}
+		}
+		if next == nil {
+			if end.After(c.t) {
+				c.t = end
+			}
+			c.mu.Unlock()
+			return
+		}
+		next.done = true
+		if next.at.After(c.t) {
+			c.t = next.at
+		}
+		c.mu.Unlock()
+		next.f()
+	}
+}
+
+// withFakeClock runs the foot switch, captures, and replays on simulated
+// time starting at the returned instant.
+func withFakeClock(t *testing.T) (*fakeClock, time.Time) {
+	t.Helper()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	fc := &fakeClock{t: start}
+	saved, savedLast, savedCooldown := clk, lastPointAddedTime.Load(), getCaptureCooldown()
+	clk = fc
+	lastPointAddedTime.Store(0)
+	captureCooldown.Store(0)
+	t.Cleanup(func() {
+		clk = saved
+		lastPointAddedTime.Store(savedLast)
+		captureCooldown.Store(int64(savedCooldown))
+		btnEventMu.Lock()
+		btnRead, btnRecheck, btnLong, btnHold = nil, nil, nil, nil
+		btnEventMu.Unlock()
+	})
+	return fc, start
+}
+
+func TestReplayBounces(t *testing.T) {
+	// The foot switch is on GPIO 26 and pulls the line low when pressed.
+	tests := []struct {
+		name       string
+		longPress  time.Duration
+		cooldown   time.Duration
+		recording  string
+		want       []time.Duration // capture times from the start
+		wantZeroed bool
+	}{
+		{
+			name:      "clean tap",
+			recording: "0.100 26 0\n0.300 26 1\n",
+			want:      []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:      "press bounces",
+			recording: "0.100 26 0\n0.102 26 1\n0.104 26 0\n0.106 26 1\n0.108 26 0\n0.400 26 1\n",
+			want:      []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:      "release bounces",
+			recording: "0.100 26 0\n0.300 26 1\n0.302 26 0\n0.304 26 1\n0.600 26 0\n0.700 26 1\n",
+			want:      []time.Duration{100 * time.Millisecond, 600 * time.Millisecond},
+		},
+		{
+			// The release falls inside the debounce window; the re-check at
+			// its end sees it, so the next press counts.
+			name:      "tap shorter than debounce",
+			recording: "0.100 26 0\n0.120 26 1\n0.300 26 0\n0.400 26 1\n",
+			want:      []time.Duration{100 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:      "cooldown",
+			cooldown:  500 * time.Millisecond,
+			recording: "0.100 26 0\n0.200 26 1\n0.400 26 0\n0.500 26 1\n0.700 26 0\n0.800 26 1\n",
+			want:      []time.Duration{100 * time.Millisecond, 700 * time.Millisecond},
+		},
+		{
+			name:      "other pins skipped",
+			recording: "# recorded on the bench\n0.100 5 0\n0.150 5 1\n0.200 26 0\n0.300 26 1\n",
+			want:      []time.Duration{200 * time.Millisecond},
+		},
+		{
+			name:       "long press zeroes",
+			longPress:  time.Second,
+			recording:  "0.100 26 0\n0.102 26 1\n0.104 26 0\n1.500 26 1\n",
+			wantZeroed: true,
+		},
+		{
+			name:      "short press captures on release",
+			longPress: time.Second,
+			recording: "0.100 26 0\n0.400 26 1\n0.402 26 0\n0.404 26 1\n",
+			want:      []time.Duration{400 * time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc, start := withFakeClock(t)
+			enc := newTestEncoder(t)
+			enc.update(1000, start)
+			c := *cfg()
+			c.LongPressMs = int(tt.longPress / time.Millisecond)
+			liveConfig.Store(&c)
+			if cfg().Pins.PointButton != 26 {
+				t.Fatalf("foot switch on GPIO %d, recordings use 26", cfg().Pins.PointButton)
+			}
+			captureCooldown.Store(int64(tt.cooldown))
+			withTestSession(t, nil)
+			events, err := readEvents(strings.NewReader(tt.recording))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			done, _ := startReplay(events)
+			fc.run()
+			select {
+			case <-done:
+			default:
+				t.Fatal("replay didn't finish")
+			}
+
+			pts := snapshotPoints()
+			if len(pts) != len(tt.want) {
+				t.Fatalf("captured %d points, want %d", len(pts), len(tt.want))
+			}
+			enc.mu.RLock()
+			wantX, pos := enc.countsToMM(1000), enc.position()
+			enc.mu.RUnlock()
+			for i, p := range pts {
+				if at := p.capturedAt.Sub(start); at != tt.want[i] {
+					t.Errorf("point %d captured at %v, want %v", i, at, tt.want[i])
+				}
+				if math.Abs(p.x-wantX) > 1e-9 {
+					t.Errorf("point %d x = %v mm, want %v", i, p.x, wantX)
+				}
+			}
+			if zeroed := pos == 0; zeroed != tt.wantZeroed {
+				t.Errorf("zeroed = %v, want %v (position %d)", zeroed, tt.wantZeroed, pos)
+			}
+		})
+	}
+}
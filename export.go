@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// exportOptions are the per-request knobs shared by the point exporters.
+type exportOptions struct {
+	closeLoop bool   // close a traced outline back to its first point
+	normalsK  int    // estimate normals from this many neighbors (0 = off)
+	header    bool   // prepend a metadata block where the format allows it
+	operator  string // operator name for the metadata block
+
+	unit       string  // output unit for formats that take one (CSV); others stay in mm
+	scale      float64 // mm per unit
+	timestamps bool    // add each point's capture time where the format allows it
+	precision  int     // decimal places for coordinates
+
+	cycle string  // G-code hole motion: "move" (G0/G1) or "drill" (G81)
+	safeZ float64 // G-code clearance height in mm; NaN means 5 mm above the highest point
+	feed  float64 // G-code plunge feed in mm/min
+
+	align *alignment // the frame the points were aligned into; nil for machine coordinates
+}
+
+// G-code export defaults.
+const (
+	defaultGCodeFeed      = 100.0 // mm/min
+	defaultGCodeClearance = 5.0   // mm above the highest point
+)
+
+// gcodeCycles are the accepted cycle query values.
+var gcodeCycles = map[string]bool{"move": true, "drill": true}
+
+// Coordinate decimal places accepted by the precision query parameter.
+const (
+	defaultExportPrecision = 6
+	minExportPrecision     = 1
+	maxExportPrecision     = 9
+)
+
+// coord formats one coordinate with opts.precision decimals.
+func (opts exportOptions) coord(v float64) string {
+	return strconv.FormatFloat(v, 'f', opts.precision, 64)
+}
+
+// pointWriter streams a point snapshot in one export format.
+type pointWriter func(w io.Writer, pts []point, opts exportOptions) error
+
+// exportFormat is one downloadable point format.
+type exportFormat struct {
+	ext         string
+	contentType string
+	write       pointWriter
+	closes      bool // draws a line through the points, so close=true applies
+}
+
+// exportFormats maps the format parameter (and export.* route) to a writer.
+var exportFormats = map[string]exportFormat{
+	"asc":   {".asc", "text/plain", writePointsASC, false},
+	"vtk":   {".vtk", "application/x-vtk", writePointsVTK, true},
+	"csv":   {".csv", "text/csv", writePointsCSV, false},
+	"ply":   {".ply", "application/x-ply", writePointsPLY, false},
+	"dxf":   {".dxf", "application/dxf", writePointsDXF, true},
+	"gcode": {".gcode", "text/plain", writePointsGCode, false},
+}
+
+// writePointsASC writes one "X Y Z" line per point in mm (FreeCAD point cloud).
+func writePointsASC(w io.Writer, pts []point, opts exportOptions) error {
+	bw := bufio.NewWriter(w)
+	if opts.header {
+		// FreeCAD skips leading # lines when importing ASC.
+		for _, line := range exportMetadata(opts, "mm", len(pts)) {
+			fmt.Fprintf(bw, "# %s\n", line)
+		}
+	}
+	for _, p := range pts {
+		fmt.Fprintf(bw, "%s %s %s\n", opts.coord(p.x), opts.coord(p.y), opts.coord(p.z))
+	}
+	return bw.Flush()
+}
+
+// writePointsCSV writes an X,Y,Z header row then one row per point in
+// opts.unit, with a trailing RFC 3339 capture time when opts.timestamps is
+// set and a Label column when any point has a label. With opts.header the
+// metadata comes first as "# " lines.
+func writePointsCSV(w io.Writer, pts []point, opts exportOptions) error {
+	labels := slices.ContainsFunc(pts, func(p point) bool { return p.label != "" })
+	if opts.header {
+		for _, line := range exportMetadata(opts, opts.unit, len(pts)) {
+			if _, err := fmt.Fprintf(w, "# %s\n", line); err != nil {
+				return err
+			}
+		}
+	}
+	cw := csv.NewWriter(w)
+	row := []string{"X", "Y", "Z"}
+	if opts.timestamps {
+		row = append(row, "CapturedAt")
+	}
+	if labels {
+		row = append(row, "Label")
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for _, p := range pts {
+		row = row[:0]
+		for _, v := range []float64{p.x, p.y, p.z} {
+			row = append(row, opts.coord(v/opts.scale))
+		}
+		if opts.timestamps {
+			row = append(row, p.capturedAt.Format(time.RFC3339Nano))
+		}
+		if labels {
+			row = append(row, p.label)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writePointsPLY writes an ASCII PLY point cloud (MeshLab, CloudCompare) in
+// mm. Metadata goes in comment lines; with normalsK each vertex also carries
+// nx ny nz.
+func writePointsPLY(w io.Writer, pts []point, opts exportOptions) error {
+	var normals [][3]float64
+	if opts.normalsK > 0 {
+		normals = estimateNormals(pts, opts.normalsK)
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("ply\nformat ascii 1.0\n")
+	if opts.header {
+		for _, line := range exportMetadata(opts, "mm", len(pts)) {
+			fmt.Fprintf(bw, "comment %s\n", line)
+		}
+	}
+	fmt.Fprintf(bw, "element vertex %d\n", len(pts))
+	bw.WriteString("property float x\nproperty float y\nproperty float z\n")
+	if normals != nil {
+		bw.WriteString("property float nx\nproperty float ny\nproperty float nz\n")
+	}
+	bw.WriteString("end_header\n")
+	for i, p := range pts {
+		fmt.Fprintf(bw, "%s %s %s", opts.coord(p.x), opts.coord(p.y), opts.coord(p.z))
+		if normals != nil {
+			fmt.Fprintf(bw, " %.6f %.6f %.6f", normals[i][0], normals[i][1], normals[i][2])
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// writePointsDXF writes a minimal ASCII DXF (the R12 subset LibreCAD and
+// FreeCAD import) with one POINT entity per point on layer 0, in mm. With
+// opts.closeLoop a closed 3D POLYLINE through the points outlines a traced
+// shape. Metadata goes in 999 comment groups.
+func writePointsDXF(w io.Writer, pts []point, opts exportOptions) error {
+	bw := bufio.NewWriter(w)
+	group := func(code int, value string) {
+		fmt.Fprintf(bw, "%d\n%s\n", code, value)
+	}
+	if opts.header {
+		for _, line := range exportMetadata(opts, "mm", len(pts)) {
+			group(999, line)
+		}
+	}
+	group(0, "SECTION")
+	group(2, "HEADER")
+	group(9, "$ACADVER")
+	group(1, "AC1009")
+	group(9, "$INSUNITS") // ignored by R12 readers, honored by newer ones
+	group(70, "4")        // millimeters
+	group(0, "ENDSEC")
+
+	group(0, "SECTION")
+	group(2, "ENTITIES")
+	for _, p := range pts {
+		group(0, "POINT")
+		group(8, "0")
+		group(10, opts.coord(p.x))
+		group(20, opts.coord(p.y))
+		group(30, opts.coord(p.z))
+	}
+	if opts.closeLoop && shouldCloseLoop(pts) {
+		group(0, "POLYLINE")
+		group(8, "0")
+		group(66, "1") // vertices follow
+		group(10, "0")
+		group(20, "0")
+		group(30, "0")
+		group(70, "9") // closed (1), 3D polyline (8)
+		for _, p := range pts {
+			group(0, "VERTEX")
+			group(8, "0")
+			group(10, opts.coord(p.x))
+			group(20, opts.coord(p.y))
+			group(30, opts.coord(p.z))
+			group(70, "32") // 3D polyline vertex
+		}
+		group(0, "SEQEND")
+		group(8, "0")
+	}
+	group(0, "ENDSEC")
+	group(0, "EOF")
+	return bw.Flush()
+}
+
+// writePointsGCode writes a plain G-code program (mm, absolute) that visits
+// each point in capture order: rapid to its XY at the safe height, then go
+// down to the captured Z, either with G1 at opts.feed or as one G81 drill
+// cycle retracting to the safe height. Spindle and tool are left to the
+// operator.
+func writePointsGCode(w io.Writer, pts []point, opts exportOptions) error {
+	top := math.Inf(-1)
+	for _, p := range pts {
+		top = max(top, p.z)
+	}
+	safe := opts.safeZ
+	if math.IsNaN(safe) {
+		safe = top + defaultGCodeClearance
+	} else if safe <= top {
+		slog.Warn("gcode safe Z is not above every point", "safeZMm", safe, "highestZMm", top)
+	}
+	bw := bufio.NewWriter(w)
+	comment := func(s string) {
+		// Parentheses would end the comment early.
+		s = strings.NewReplacer("(", "[", ")", "]").Replace(s)
+		fmt.Fprintf(bw, "(%s)\n", s)
+	}
+	comment(fmt.Sprintf("closinuf %s program, %d points", opts.cycle, len(pts)))
+	if opts.header {
+		for _, line := range exportMetadata(opts, "mm", len(pts)) {
+			comment(line)
+		}
+	}
+	feed := opts.coord(opts.feed)
+	bw.WriteString("G21 G90 G94 G17\n")
+	fmt.Fprintf(bw, "G0 Z%s\n", opts.coord(safe))
+	for i, p := range pts {
+		x, y, z := opts.coord(p.x), opts.coord(p.y), opts.coord(p.z)
+		switch {
+		case opts.cycle == "drill" && i == 0:
+			fmt.Fprintf(bw, "G98 G81 X%s Y%s Z%s R%s F%s\n", x, y, z, opts.coord(safe), feed)
+		case opts.cycle == "drill":
+			fmt.Fprintf(bw, "X%s Y%s Z%s\n", x, y, z)
+		default:
+			fmt.Fprintf(bw, "G0 X%s Y%s\n", x, y)
+			fmt.Fprintf(bw, "G1 Z%s F%s\n", z, feed)
+			fmt.Fprintf(bw, "G0 Z%s\n", opts.coord(safe))
+		}
+	}
+	if opts.cycle == "drill" {
+		bw.WriteString("G80\n")
+	}
+	bw.WriteString("M2\n")
+	return bw.Flush()
+}
+
+// writePointsVTK writes a legacy VTK PolyData file (ParaView): one vertex per
+// point plus a polyline through them in capture order. With closeLoop the
+// polyline returns to the first point if the ends are within tolerance; with
+// normalsK the file carries estimated point normals.
+func writePointsVTK(w io.Writer, pts []point, opts exportOptions) error {
+	n := len(pts)
+	bw := bufio.NewWriter(w)
+	bw.WriteString("# vtk DataFile Version 3.0\n")
+	if opts.header {
+		// The title line is VTK's only free-text field (one line, 256 chars max).
+		title := strings.Join(exportMetadata(opts, "mm", n), "; ")
+		bw.WriteString(truncateUTF8(title, 255) + "\n")
+	} else {
+		bw.WriteString("closinuf points (mm)\n")
+	}
+	bw.WriteString("ASCII\n")
+	bw.WriteString("DATASET POLYDATA\n")
+	fmt.Fprintf(bw, "POINTS %d double\n", n)
+	for _, p := range pts {
+		fmt.Fprintf(bw, "%s %s %s\n", opts.coord(p.x), opts.coord(p.y), opts.coord(p.z))
+	}
+	fmt.Fprintf(bw, "VERTICES %d %d\n", n, 2*n)
+	for i := range pts {
+		fmt.Fprintf(bw, "1 %d\n", i)
+	}
+	line := make([]int, n, n+1)
+	for i := range line {
+		line[i] = i
+	}
+	if opts.closeLoop && shouldCloseLoop(pts) {
+		line = append(line, 0)
+	}
+	fmt.Fprintf(bw, "LINES 1 %d\n%d", len(line)+1, len(line))
+	for _, i := range line {
+		fmt.Fprintf(bw, " %d", i)
+	}
+	bw.WriteString("\n")
+	if opts.normalsK > 0 {
+		if normals := estimateNormals(pts, opts.normalsK); normals != nil {
+			fmt.Fprintf(bw, "POINT_DATA %d\nNORMALS normals double\n", n)
+			for _, nv := range normals {
+				fmt.Fprintf(bw, "%.6f %.6f %.6f\n", nv[0], nv[1], nv[2])
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// maxOperatorName is the longest operator= the export headers accept.
+const maxOperatorName = 64
+
+// validateOperator trims an operator name and rejects one that is too long
+// or has control characters: a newline would start a fake data line in the
+// ASC, CSV, and PLY headers.
+func validateOperator(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if len(name) > maxOperatorName {
+		return "", fmt.Errorf("operator longer than %d bytes", maxOperatorName)
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return "", fmt.Errorf("operator %q contains control characters", name)
+	}
+	return name, nil
+}
+
+// exportMetadata describes an export for its header block, whose
+// coordinates are in unit.
+func exportMetadata(opts exportOptions, unit string, n int) []string {
+	lines := []string{
+		"closinuf " + version,
+		"exported: " + time.Now().Format(time.RFC3339),
+	}
+	if opts.operator != "" {
+		lines = append(lines, "operator: "+opts.operator)
+	}
+	if al := opts.align; al != nil {
+		lines = append(lines, fmt.Sprintf("frame: aligned to edge at %.4f°, origin X%.4f Y%.4f mm", al.Angle*180/math.Pi, al.OriginX, al.OriginY))
+	}
+	return append(lines, "units: "+unit, fmt.Sprintf("points: %d", n))
+}
+
+// shouldCloseLoop reports whether a traced outline ends close enough to its
+// start to be closed, logging a warning when closing was asked for but the
+// ends are too far apart.
+func shouldCloseLoop(pts []point) bool {
+	if len(pts) < 3 {
+		return false
+	}
+	first, last := pts[0], pts[len(pts)-1]
+	gap := math.Sqrt(dist2(first, last))
+	if tol := cfg().closeTolerance(); gap > tol {
+		slog.Warn("not closing loop: last point too far from the first", "gapMm", gap, "toleranceMm", tol)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// withTestSession makes a fresh session holding pts the active one.
+func withTestSession(t *testing.T, pts []point) {
+	t.Helper()
+	s := newSession("test", "mm")
+	s.points = pts
+	pointsMu.Lock()
+	saved := active
+	active = s
+	pointsMu.Unlock()
+	t.Cleanup(func() {
+		pointsMu.Lock()
+		active = saved
+		pointsMu.Unlock()
+	})
+}
+
+// TestSendPointsMatchesWritePoints checks that each streamed download is
+// byte for byte what its writer produces in memory, for a large session.
+func TestSendPointsMatchesWritePoints(t *testing.T) {
+	const n = 50000
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	pts := make([]point, n)
+	for i := range pts {
+		a := float64(i) / 100
+		pts[i] = point{
+			x:          100 * math.Cos(a),
+			y:          100 * math.Sin(a),
+			z:          float64(i) / 1000,
+			source:     sourceEncoder,
+			capturedAt: t0.Add(time.Duration(i) * time.Second),
+		}
+	}
+	withTestSession(t, pts)
+
+	app := fiber.New()
+	app.Get("/save", func(c *fiber.Ctx) error {
+		return sendPoints(c, c.Query("format"))
+	})
+	opts := exportOptions{
+		unit:      "mm",
+		scale:     1,
+		precision: defaultExportPrecision,
+		cycle:     "move",
+		safeZ:     math.NaN(),
+		feed:      defaultGCodeFeed,
+	}
+	for format, f := range exportFormats {
+		t.Run(format, func(t *testing.T) {
+			var want bytes.Buffer
+			if err := f.write(&want, pts, opts); err != nil || want.Len() == 0 {
+				t.Fatalf("writing in memory: %d bytes, err %v", want.Len(), err)
+			}
+			resp, err := app.Test(httptest.NewRequest("GET", "/save?header=false&format="+format, nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != 200 {
+				t.Fatalf("status %d: %s", resp.StatusCode, got)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Errorf("streamed %d bytes differ from the %d written in memory", len(got), want.Len())
+			}
+		})
+	}
+}
+
+func TestSendPointsOperator(t *testing.T) {
+	withTestSession(t, []point{{x: 1, y: 2, z: 3}})
+	app := fiber.New()
+	app.Get("/save", func(c *fiber.Ctx) error {
+		return sendPoints(c, "asc")
+	})
+	tests := []struct {
+		operator string // URL-encoded
+		status   int
+		header   string
+	}{
+		{"Ada", 200, "# operator: Ada\n"},
+		{"%20Ada%20Lovelace%20", 200, "# operator: Ada Lovelace\n"},
+		{"x%0A1%202%203", 400, ""},
+		{"x%09y", 400, ""},
+		{strings.Repeat("a", maxOperatorName+1), 400, ""},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", "/save?header=true&operator="+tt.operator, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("operator=%s: status %d, want %d", tt.operator, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.header != "" && !strings.Contains(string(body), tt.header) {
+			t.Errorf("operator=%s: body %q lacks %q", tt.operator, body, tt.header)
+		}
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"abc", 5, "abc"},
+		{"abc", 3, "abc"},
+		{"abcdef", 3, "abc"},
+		{"ab°c", 3, "ab"}, // ° is 2 bytes, starting at 2
+		{"ab°c", 4, "ab°"},
+		{"€", 2, ""},
+	}
+	for _, tt := range tests {
+		got := truncateUTF8(tt.s, tt.n)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncateUTF8(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+// TestArchiveModeRoundTrip checks that readArchive accepts the mode
+// snapshotArchive writes, in both ABS and INC.
+func TestArchiveModeRoundTrip(t *testing.T) {
+	newTestEncoder(t)
+	withTestSession(t, []point{{x: 1, y: 2, z: 3, source: sourceManual}})
+	t.Cleanup(func() { incMode.Store(false) })
+	for _, inc := range []bool{false, true} {
+		incMode.Store(inc)
+		data, err := json.Marshal(snapshotArchive())
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := readArchive(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("inc=%v: readArchive: %v", inc, err)
+		}
+		if a.Mode != coordMode() {
+			t.Errorf("inc=%v: mode = %q, want %q", inc, a.Mode, coordMode())
+		}
+	}
+	if _, err := readArchive(strings.NewReader(`{"schema": 1, "mode": "ABS"}`)); err == nil {
+		t.Error(`readArchive accepted mode "ABS"`)
+	}
+}
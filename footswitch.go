@@ -0,0 +1,223 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	btnEventMu  sync.Mutex
+	pointButton button
+	btnRead     func() (int, error) // reads the switch level for re-checks; nil when there is no switch
+	btnRecheck  timer               // pending level re-check after a rejected edge
+	btnHold     *holdSampler        // sampling the current press (hold-to-average only)
+	btnLong     timer               // pending long-press action for the current press
+	btnLongDone bool                // the current press already acted as a long press
+)
+
+// resetPointButton sets up the debouncer from the config, released.
+// Caller holds btnEventMu.
+func resetPointButton() {
+	active := 0
+	if cfg().ButtonActiveHigh {
+		active = 1
+	}
+	pointButton = button{debounce: cfg().buttonDebounce(), active: active, longPress: cfg().longPress()}
+}
+
+// button debounces the foot switch. A level change is accepted only once
+// debounce has passed since the last accepted change; bounces inside that
+// window are rejected, and the caller re-reads the line when the window ends
+// so a short tap whose release bounced away isn't left stuck down.
+type button struct {
+	debounce  time.Duration
+	longPress time.Duration // press length that counts as long (0 = no long presses)
+	active    int           // line level when pressed
+	pressed   bool
+	changed   time.Time // last accepted change
+}
+
+// heldLong reports whether the current press has lasted a long press at now.
+func (b *button) heldLong(now time.Time) bool {
+	return b.longPress > 0 && b.pressed && now.Sub(b.changed) >= b.longPress
+}
+
+// level feeds the line level seen at now and reports an accepted press or
+// release. When a change is rejected, recheck is how long to wait before
+// feeding the then-current level again.
+func (b *button) level(level int, now time.Time) (pressed, released bool, recheck time.Duration) {
+	down := level == b.active
+	if down == b.pressed {
+		return false, false, 0
+	}
+	if wait := b.debounce - now.Sub(b.changed); wait > 0 {
+		return false, false, wait
+	}
+	b.pressed = down
+	b.changed = now
+	return down, !down, 0
+}
+
+func onPointButtonLevel(level int) {
+	btnEventMu.Lock()
+	defer btnEventMu.Unlock()
+
+	pressed, released, recheck := pointButton.level(level, clk.now())
+	slog.Debug("button level", "level", level, "pressed", pressed, "released", released, "recheck", recheck)
+	if recheck > 0 && btnRecheck == nil && btnRead != nil {
+		btnRecheck = clk.afterFunc(recheck, func() {
+			btnEventMu.Lock()
+			btnRecheck = nil
+			read := btnRead
+			btnEventMu.Unlock()
+			if read == nil {
+				return // closed on shutdown
+			}
+			if v, err := read(); err == nil {
+				onPointButtonLevel(v)
+			}
+		})
+	}
+
+	switch {
+	case pressed:
+		buttonPresses.Add(1)
+		if pointButton.longPress > 0 {
+			// Capture on release, unless the press turns out long.
+			btnLongDone = false
+			btnLong = clk.afterFunc(pointButton.longPress, onPointButtonLong)
+			return
+		}
+		if !captureAllowed() {
+			return
+		}
+		if cfg().AverageHoldMs > 0 {
+			btnHold = startHoldSampler()
+			return
+		}
+		captureFromButton()
+	case released:
+		if btnLong != nil {
+			btnLong.Stop()
+			btnLong = nil
+			if !btnLongDone && captureAllowed() {
+				captureFromButton()
+			}
+			return
+		}
+		if btnHold != nil {
+			_, err := addCapturedPoint(btnHold.finish(cfg().averageHold()))
+			btnHold = nil
+			if err != nil {
+				slog.Info("foot switch capture rejected", "err", err)
+				return
+			}
+			playBeep()
+		}
+	}
+}
+
+// stopPointButton cancels the switch's pending re-check, long press, and
+// hold sampling, e.g. on shutdown. Caller holds btnEventMu.
+func stopPointButton() {
+	if btnRecheck != nil {
+		btnRecheck.Stop()
+		btnRecheck = nil
+	}
+	if btnLong != nil {
+		btnLong.Stop()
+		btnLong = nil
+	}
+	if btnHold != nil {
+		btnHold.stop()
+		btnHold = nil
+	}
+}
+
+func captureFromButton() {
+	if err := addCapturePoint(""); err != nil {
+		slog.Info("foot switch capture rejected", "err", err)
+		return
+	}
+	playBeep()
+}
+
+// onPointButtonLong zeroes every axis once a press has lasted longPress.
+// Captured points are kept, as with per-axis zero.
+func onPointButtonLong() {
+	btnEventMu.Lock()
+	defer btnEventMu.Unlock()
+	// A timer that fired just as its press ended may find a newer press.
+	if btnLong == nil || !pointButton.heldLong(clk.now()) {
+		return
+	}
+	btnLongDone = true
+	zeroEncoderCounts()
+	slog.Info("foot switch long press: zeroed all axes")
+	playBeep()
+}
+
+// holdSampler samples the live position every holdSampleEvery on clk while
+// the foot switch is held.
+type holdSampler struct {
+	start time.Time
+	first point
+
+	mu      sync.Mutex
+	next    timer // the pending sample
+	stopped bool
+	sum     point
+	n       int
+}
+
+func startHoldSampler() *holdSampler {
+	h := &holdSampler{start: clk.now(), first: livePoint()}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(h.first)
+	h.next = clk.afterFunc(holdSampleEvery, h.sample)
+	return h
+}
+
+// sample adds the live position and schedules the next sample.
+func (h *holdSampler) sample() {
+	p := livePoint()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	h.add(p)
+	h.next = clk.afterFunc(holdSampleEvery, h.sample)
+}
+
+// add sums p into the average. Caller holds h.mu.
+func (h *holdSampler) add(p point) {
+	h.sum.x += p.x
+	h.sum.y += p.y
+	h.sum.z += p.z
+	h.n++
+}
+
+// stop ends sampling; a sample already running is dropped.
+func (h *holdSampler) stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stopped = true
+	h.next.Stop()
+}
+
+// finish stops sampling and returns the point to store: the average of the
+// samples if the press lasted at least minHold, else the position at press.
+func (h *holdSampler) finish(minHold time.Duration) point {
+	h.stop()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if clk.now().Sub(h.start) < minHold {
+		h.first.capturedAt = h.start
+		return h.first
+	}
+	n := float64(h.n)
+	return point{x: h.sum.x / n, y: h.sum.y / n, z: h.sum.z / n, source: sourceAverage, capturedAt: h.start}
+}
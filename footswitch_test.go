@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestButtonHeldLong(t *testing.T) {
+	const longPress = 2 * time.Second
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		longPress time.Duration
+		release   bool // released again 100 ms after the press
+		at        time.Duration
+		want      bool
+	}{
+		{"just pressed", longPress, false, 0, false},
+		{"just under", longPress, false, longPress - time.Millisecond, false},
+		{"exactly long", longPress, false, longPress, true},
+		{"past long", longPress, false, longPress + time.Second, true},
+		{"released", longPress, true, longPress + time.Second, false},
+		{"long presses off", 0, false, time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := button{debounce: 10 * time.Millisecond, active: 1, longPress: tt.longPress}
+			if pressed, _, _ := b.level(1, t0); !pressed {
+				t.Fatal("press not accepted")
+			}
+			if tt.release {
+				if _, released, _ := b.level(0, t0.Add(100*time.Millisecond)); !released {
+					t.Fatal("release not accepted")
+				}
+			}
+			if got := b.heldLong(t0.Add(tt.at)); got != tt.want {
+				t.Errorf("heldLong at +%v = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHoldSamplerOnClock(t *testing.T) {
+	fc, start := withFakeClock(t)
+	enc := newTestEncoder(t)
+	h := startHoldSampler()
+	fc.runUntil(start.Add(120 * time.Millisecond)) // samples at 0, 50, 100 ms
+	enc.update(1000, start.Add(120*time.Millisecond))
+	fc.runUntil(start.Add(200 * time.Millisecond)) // and 150, 200 ms
+	p := h.finish(100 * time.Millisecond)
+	fc.run() // returns only if sampling stopped
+
+	if h.n != 5 {
+		t.Errorf("took %d samples, want 5", h.n)
+	}
+	enc.mu.RLock()
+	want := 2 * enc.countsToMM(1000) / 5
+	enc.mu.RUnlock()
+	if math.Abs(p.x-want) > 1e-9 || p.source != sourceAverage || !p.capturedAt.Equal(start) {
+		t.Errorf("finish = x %v, source %s, at %v; want x %v, %s, %v", p.x, p.source, p.capturedAt, want, sourceAverage, start)
+	}
+}
+
+func TestHoldSamplerShortPress(t *testing.T) {
+	fc, start := withFakeClock(t)
+	newTestEncoder(t)
+	h := startHoldSampler()
+	fc.runUntil(start.Add(60 * time.Millisecond))
+	p := h.finish(100 * time.Millisecond)
+	if p.source != sourceEncoder || !p.capturedAt.Equal(start) {
+		t.Errorf("finish = source %s at %v, want the position at press", p.source, p.capturedAt)
+	}
+}
+
+func TestStopPointButtonStopsSampler(t *testing.T) {
+	fc, _ := withFakeClock(t)
+	newTestEncoder(t)
+	btnEventMu.Lock()
+	btnHold = startHoldSampler()
+	h := btnHold
+	stopPointButton()
+	btnEventMu.Unlock()
+	fc.run() // returns only if sampling stopped
+	if btnHold != nil {
+		t.Error("btnHold still set")
+	}
+	if h.n != 1 {
+		t.Errorf("took %d samples after stopping, want just the first", h.n)
+	}
+}
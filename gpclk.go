@@ -1,8 +1,11 @@
+//go:build linux
+
 package main
 
 import (
 	"encoding/binary"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 	"unsafe"
@@ -22,10 +25,8 @@ const (
 	offGP0CTL = 0x70
 	offGP0DIV = 0x74
 
-	// GPCLK0 source: 1 = oscillator (19.2 MHz). DIVI=2, DIVF=546 → ~9 MHz.
+	// GPCLK0 source: 1 = oscillator (19.2 MHz); divider in counters.go.
 	gpclkSrcOsc  = 1
-	gpclkDivInt  = 2
-	gpclkDivFrac = 546
 	gpclkDivVal  = bcmClkPassword | (uint32(gpclkDivInt) << 12) | gpclkDivFrac
 	gpclkDivMask = uint32(0x00ffffff) // readback: no password byte
 )
@@ -40,7 +41,7 @@ func initGPCLK() error {
 	if err != nil {
 		return err
 	}
-	fmt.Fprintf(os.Stderr, "GPCLK0_CTL=0x%08x GPCLK0_DIV=0x%08x after setup\n", ctl, div)
+	slog.Debug("GPCLK0 registers after setup", "ctl", fmt.Sprintf("0x%08x", ctl), "div", fmt.Sprintf("0x%08x", div))
 	return nil
 }
 
@@ -101,7 +102,7 @@ func programGPCLK() error {
 		return fmt.Errorf("GPCLK0 enable bit not set after programming")
 	}
 
-	fmt.Fprintf(os.Stderr, "GPCLK0 enabled on GPIO4 (~9 MHz, OSC) for LS7366R fCKi\n")
+	slog.Info("GPCLK0 enabled on GPIO4 (~9 MHz, OSC) for LS7366R fCKi")
 	return nil
 }
 
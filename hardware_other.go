@@ -0,0 +1,25 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// The LS7366R bank and the foot switch need Linux SPI, /dev/mem, and GPIO
+// character devices; elsewhere only the mock backend runs.
+
+func initCounters() (counterSource, error) {
+	return nil, fmt.Errorf("LS7366R counters need Linux on a Raspberry Pi (set CLOSINUF_BACKEND=mock to simulate)")
+}
+
+func initPointButton() error {
+	return fmt.Errorf("foot switch needs Linux GPIO")
+}
+
+// closePointButton cancels whatever a mock replay left pending; there is no
+// line to release.
+func closePointButton() {
+	btnEventMu.Lock()
+	btnRead = nil
+	stopPointButton()
+	btnEventMu.Unlock()
+}
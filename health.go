@@ -0,0 +1,53 @@
+package main
+
+import "time"
+
+// startupStatus records how initialization went, for /healthz. Counter or
+// GPIO failures during setup are fatal, so a running process got this far;
+// what can go wrong afterwards is an axis whose counter stops answering.
+type startupStatus struct {
+	started time.Time
+	backend string // "ls7366r" or "mock"
+	button  string // "ok", or "disabled" with the mock backend
+}
+
+var startup = startupStatus{started: time.Now()}
+
+// axisHealth is one axis's counter status as served by /healthz.
+type axisHealth struct {
+	Label       string `json:"label"`
+	Status      string `json:"status"`      // "ok", or "failing" while reads fail
+	ErrorStreak int    `json:"errorStreak"` // consecutive failed reads
+	Errors      int    `json:"errors"`      // failed reads since the last errors reset
+	Signal      string `json:"signal"`      // signalOK, signalStale, or signalNoisy
+}
+
+type healthReport struct {
+	Status    string       `json:"status"` // "ok" or "degraded"
+	Backend   string       `json:"backend"`
+	Button    string       `json:"button"`
+	Axes      []axisHealth `json:"axes"`
+	UptimeSec float64      `json:"uptimeSec"`
+}
+
+// getHealth reports startup results and per-axis counter health; ok is
+// false when any axis's last read failed.
+func getHealth() (report healthReport, ok bool) {
+	report = healthReport{
+		Status:    "ok",
+		Backend:   startup.backend,
+		Button:    startup.button,
+		UptimeSec: time.Since(startup.started).Seconds(),
+	}
+	for _, enc := range enabledEncoders() {
+		enc.mu.RLock()
+		a := axisHealth{Label: enc.label, Status: "ok", ErrorStreak: enc.errorStreak, Errors: enc.readErrors, Signal: enc.signal(time.Now())}
+		enc.mu.RUnlock()
+		if a.ErrorStreak > 0 {
+			a.Status = "failing"
+			report.Status = "degraded"
+		}
+		report.Axes = append(report.Axes, a)
+	}
+	return report, report.Status == "ok"
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportBytes caps uploads to /api/points/import (~100k points of ASC).
+const maxImportBytes = 4 << 20
+
+// importResult reports what an import did with each line.
+type importResult struct {
+	Loaded  int `json:"loaded"`
+	Skipped int `json:"skipped"` // blank, comment, header, or unparsable lines
+}
+
+// readPoints parses ASC ("X Y Z" per line) or CSV ("X,Y,Z", header row
+// allowed) text in units of scale mm. Lines that are blank, start with #, or
+// don't begin with three numbers are skipped and counted; extra columns such
+// as a CSV timestamp are ignored.
+func readPoints(r io.Reader, scale float64) ([]point, int, error) {
+	var pts []point
+	skipped := 0
+	now := time.Now()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			skipped++
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t'
+		})
+		if len(fields) < 3 {
+			skipped++
+			continue
+		}
+		var xyz [3]float64
+		ok := true
+		for i := range xyz {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				ok = false
+				break
+			}
+			xyz[i] = v * scale
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+		pts = append(pts, point{x: xyz[0], y: xyz[1], z: xyz[2], source: sourceImport, capturedAt: now})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, skipped, fmt.Errorf("read points: %w", err)
+	}
+	return pts, skipped, nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// initLogging sends slog output to stderr as text, dropping records below
+// level (debug, info, warn, or error).
+func initLogging(level string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("-log-level: %w", err)
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: l})))
+	return nil
+}
+
+// fatal logs err and exits.
+func fatal(err error) {
+	slog.Error("fatal", "err", err)
+	os.Exit(1)
+}
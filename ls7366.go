@@ -1,10 +1,10 @@
+//go:build linux
+
 package main
 
 import (
 	"fmt"
-	"os"
-	"sync"
-	"time"
+	"log/slog"
 	"unsafe"
 
 	"github.com/warthog618/go-gpiocdev"
@@ -19,20 +19,22 @@ const (
 	ls7366ReadMDR1  = 0x50
 	ls7366ReadSTR   = 0x70
 	ls7366ClrCNTR   = 0x20
+	ls7366ClrSTR    = 0x30
 	ls7366ReadCNTR  = 0x60
+	ls7366WriteDTR  = 0x98
 
-	ls7366MDR0 = 0x03 // x4 quadrature, free-run, index disabled
+	ls7366MDR0 = 0x00 // free-run, index disabled, filter clock ÷1; count mode below
 	ls7366MDR1 = 0x00 // 32-bit counter, counting enabled
 
-	spiDevPath   = "/dev/spidev0.0"
-	spiSpeedHz   = 1000000
-	spiMode      = 0
-	spiBits      = 8
-	spiChipName = "gpiochip0"
-)
+	ls7366MDR0IndexLoad = 0x10 // MDR0 index field: INDEX/ loads CNTR from DTR (asynchronous)
+	ls7366MDR0FilterDiv = 0x80 // MDR0 filter clock division factor 2
+	ls7366STRIndex      = 0x10 // STR IDX latch
 
-// CS GPIO order: U1 (X), U2 (X'), U3 (Y), U4 (Z).
-var ls7366CSGPIOs = []int{8, 7, 5, 6}
+	spiDevPath = "/dev/spidev0.0"
+	spiSpeedHz = 1000000
+	spiMode    = 0
+	spiBits    = 8
+)
 
 const (
 	spiIOCMagic         = 'k'
@@ -59,15 +61,18 @@ type spiIocTransfer struct {
 	pad            uint8
 }
 
-// counterBank drives four LS7366R chips on SPI0 with manual chip selects.
+// ls7366CountModes maps edges counted per quadrature cycle to MDR0 bits 0-1.
+var ls7366CountModes = map[int]byte{1: 0x01, 2: 0x02, 4: 0x03}
+
+// counterBank drives one LS7366R chip per axis (four on the HAT) on SPI0
+// with manual chip selects.
 type counterBank struct {
 	spiFd   int
-	csLines *gpiocdev.Lines
-	mu      sync.Mutex
+	csLines *gpiocdev.Lines // chip selects of the enabled axes only
+	csLine  []int           // chip → index into csLines, -1 for a disabled axis
+	mdr0    []byte          // per-chip MDR0 (filter divide differs by axis)
 }
 
-var bank *counterBank
-
 func spiIOCMessage(n int) uintptr {
 	return spiIOW(0, n*spiIocTransferBytes)
 }
@@ -96,11 +101,25 @@ func openCounterBank() (*counterBank, error) {
 	speed := uint32(spiSpeedHz)
 	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), spiIOW(4, 4), uintptr(unsafe.Pointer(&speed))); errno != 0 {
 		// Per-transfer speed_hz still applies; some kernels omit global max-speed ioctl.
-		fmt.Fprintf(os.Stderr, "Note: SPI_IOC_WR_MAX_SPEED_HZ: %v (using per-transfer speed)\n", errno)
+		slog.Info("SPI_IOC_WR_MAX_SPEED_HZ failed, using per-transfer speed", "err", errno)
+	}
+
+	// CS GPIO order follows pins.chipSelects: by default U1 (X), U2 (X'),
+	// U3 (Y), U4 (Z). A disabled axis's
+	// chip select isn't requested at all; the Pi's default pull-up on
+	// GPIO0–8 keeps that chip deselected.
+	bank := &counterBank{spiFd: fd, csLine: make([]int, len(encoders)), mdr0: make([]byte, len(encoders))}
+	var pins, idle []int
+	for chip, enc := range encoders {
+		bank.csLine[chip] = -1
+		if !enc.disabled {
+			bank.csLine[chip] = len(pins)
+			pins = append(pins, cfg().Pins.ChipSelects[chip])
+			idle = append(idle, 1)
+		}
 	}
-
-	csLines, err := gpiocdev.RequestLines(spiChipName, ls7366CSGPIOs,
-		gpiocdev.AsOutput(1, 1, 1, 1),
+	csLines, err := gpiocdev.RequestLines(cfg().Pins.Chip, pins,
+		gpiocdev.AsOutput(idle...),
 		gpiocdev.WithConsumer("ls7366-cs"),
 	)
 	if err != nil {
@@ -111,7 +130,13 @@ func openCounterBank() (*counterBank, error) {
 		)
 	}
 
-	bank := &counterBank{spiFd: fd, csLines: csLines}
+	bank.csLines = csLines
+	for chip, enc := range encoders {
+		bank.mdr0[chip] = ls7366MDR0 | ls7366CountModes[enc.quadrature]
+		if cfg().axis(enc.label).filterDivide() == 2 {
+			bank.mdr0[chip] |= ls7366MDR0FilterDiv
+		}
+	}
 	if err := bank.initAll(); err != nil {
 		bank.close()
 		return nil, err
@@ -134,10 +159,13 @@ func (b *counterBank) transfer(chip int, tx, rx []byte) error {
 	if len(tx) != len(rx) {
 		return fmt.Errorf("tx/rx length mismatch")
 	}
-	if err := b.csLines.SetValues(csMask(chip)); err != nil {
+	if b.csLine[chip] < 0 {
+		return fmt.Errorf("U%d is disabled", chip+1)
+	}
+	if err := b.csLines.SetValues(b.csMask(chip)); err != nil {
 		return err
 	}
-	defer b.csLines.SetValues([]int{1, 1, 1, 1})
+	defer b.csLines.SetValues(b.csMask(-1))
 
 	txPtr := uintptr(0)
 	rxPtr := uintptr(0)
@@ -160,9 +188,15 @@ func (b *counterBank) transfer(chip int, tx, rx []byte) error {
 	return nil
 }
 
-func csMask(chip int) []int {
-	m := []int{1, 1, 1, 1}
-	m[chip] = 0
+// csMask is the chip-select levels that select chip alone (-1: none).
+func (b *counterBank) csMask(chip int) []int {
+	m := make([]int, len(b.csLines.Offsets()))
+	for i := range m {
+		m[i] = 1
+	}
+	if chip >= 0 {
+		m[b.csLine[chip]] = 0
+	}
 	return m
 }
 
@@ -191,8 +225,8 @@ func (b *counterBank) verifyChip(chip int) error {
 	if err != nil {
 		return fmt.Errorf("U%d READ_MDR0: %w", chip+1, err)
 	}
-	if mdr0 != ls7366MDR0 {
-		return fmt.Errorf("U%d READ_MDR0: got 0x%02x want 0x%02x", chip+1, mdr0, ls7366MDR0)
+	if mdr0 != b.mdr0[chip] {
+		return fmt.Errorf("U%d READ_MDR0: got 0x%02x want 0x%02x", chip+1, mdr0, b.mdr0[chip])
 	}
 
 	count, err := b.readCounter(chip)
@@ -203,7 +237,7 @@ func (b *counterBank) verifyChip(chip int) error {
 		return fmt.Errorf("U%d READ_CNTR after clear: got %d want 0", chip+1, count)
 	}
 
-	fmt.Fprintf(os.Stderr, "U%d SPI OK (MDR0=0x%02x MDR1=0x%02x CNTR=0)\n", chip+1, mdr0, mdr1)
+	slog.Debug("SPI OK", "chip", chip+1, "mdr0", fmt.Sprintf("0x%02x", mdr0), "mdr1", fmt.Sprintf("0x%02x", mdr1))
 	return nil
 }
 
@@ -211,7 +245,7 @@ func (b *counterBank) initChip(chip int) error {
 	if err := b.writeReg(chip, ls7366WriteMDR1, ls7366MDR1); err != nil {
 		return err
 	}
-	if err := b.writeReg(chip, ls7366WriteMDR0, ls7366MDR0); err != nil {
+	if err := b.writeReg(chip, ls7366WriteMDR0, b.mdr0[chip]); err != nil {
 		return err
 	}
 	if err := b.command(chip, ls7366ClrCNTR); err != nil {
@@ -221,7 +255,8 @@ func (b *counterBank) initChip(chip int) error {
 }
 
 func (b *counterBank) initAll() error {
-	for chip := 0; chip < 4; chip++ {
+	for _, enc := range enabledEncoders() {
+		chip := enc.chip
 		if err := b.initChip(chip); err != nil {
 			return fmt.Errorf("init U%d: %w", chip+1, err)
 		}
@@ -265,71 +300,51 @@ func (b *counterBank) readStatus(chip int) (byte, error) {
 	return b.readReg8(chip, ls7366ReadSTR)
 }
 
-func (b *counterBank) clearAll() error {
-	for chip := 0; chip < 4; chip++ {
-		if err := b.command(chip, ls7366ClrCNTR); err != nil {
-			return fmt.Errorf("clear U%d: %w", chip+1, err)
-		}
+// armIndex preloads DTR with count, clears any stale IDX latch, and enables
+// load-on-index so the next INDEX/ pulse sets CNTR to count.
+func (b *counterBank) armIndex(chip int, count int32) error {
+	v := uint32(count)
+	tx := []byte{ls7366WriteDTR, byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	if err := b.transfer(chip, tx, make([]byte, len(tx))); err != nil {
+		return fmt.Errorf("U%d WRITE_DTR: %w", chip+1, err)
 	}
-	return nil
+	if err := b.command(chip, ls7366ClrSTR); err != nil {
+		return fmt.Errorf("U%d CLR_STR: %w", chip+1, err)
+	}
+	return b.writeReg(chip, ls7366WriteMDR0, b.mdr0[chip]|ls7366MDR0IndexLoad)
 }
 
-func initCounters() error {
+// indexFired reports whether an armed index pulse has loaded CNTR. Once it
+// has, index is disabled again so later pulses leave the count alone.
+func (b *counterBank) indexFired(chip int) (bool, error) {
+	str, err := b.readStatus(chip)
+	if err != nil {
+		return false, fmt.Errorf("U%d READ_STR: %w", chip+1, err)
+	}
+	if str&ls7366STRIndex == 0 {
+		return false, nil
+	}
+	if err := b.writeReg(chip, ls7366WriteMDR0, b.mdr0[chip]); err != nil {
+		return true, fmt.Errorf("U%d WRITE_MDR0: %w", chip+1, err)
+	}
+	return true, b.command(chip, ls7366ClrSTR)
+}
+
+// initCounters opens the LS7366R bank and starts the GPCLK0 filter clock.
+func initCounters() (counterSource, error) {
 	// SPI and chip init first, then GPCLK (DIV must be written after a full kill/BUSY cycle).
 	cb, err := openCounterBank()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if err := initGPCLK(); err != nil {
 		cb.close()
-		return fmt.Errorf("GPCLK0 on GPIO4: %w", err)
+		return nil, fmt.Errorf("GPCLK0 on GPIO4: %w", err)
 	}
 	if !gpclkEnabledInHW() {
 		cb.close()
-		return fmt.Errorf("GPCLK0 not enabled after setup")
-	}
-	bank = cb
-	fmt.Fprintf(os.Stderr, "LS7366R counters initialized on SPI0 (32-bit mode)\n")
-	return nil
-}
-
-func pollCountersForever() {
-	const interval = 50 * time.Millisecond
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		if bank == nil {
-			continue
-		}
-		bank.mu.Lock()
-		for chip, enc := range encoders {
-			count, err := bank.readCounter(chip)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "U%d READ_CNTR: %v\n", chip+1, err)
-				continue
-			}
-			enc.mu.Lock()
-			enc.counter = int(count)
-			now := time.Now()
-			elapsedSec := now.Sub(enc.lastReadTime).Seconds()
-			if elapsedSec > 0 {
-				delta := enc.counter - enc.lastReadCount
-				enc.rpm = (float64(delta) / countsPerRev) * (60.0 / elapsedSec)
-			}
-			enc.lastReadCount = enc.counter
-			enc.lastReadTime = now
-			enc.mu.Unlock()
-		}
-		bank.mu.Unlock()
-	}
-}
-
-func clearHardwareCounters() error {
-	if bank == nil {
-		return fmt.Errorf("counter bank not initialized")
+		return nil, fmt.Errorf("GPCLK0 not enabled after setup")
 	}
-	bank.mu.Lock()
-	defer bank.mu.Unlock()
-	return bank.clearAll()
+	slog.Info("LS7366R counters initialized on SPI0 (32-bit mode)")
+	return cb, nil
 }
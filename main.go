@@ -1,23 +1,94 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	g "maragu.dev/gomponents"
 )
+// version is stamped into export headers; set it at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// workers tracks the long-lived goroutines that shutdown waits for.
+var workers sync.WaitGroup
+
+// startWorker runs fn in a goroutine tracked by workers. fn must return once
+// its context is cancelled.
+func startWorker(fn func()) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		fn()
+	}()
+}
+
 func main() {
-	if err := initEncoders(); err != nil {
-		fmt.Fprintf(os.Stderr, "Fatal: %v\n", err)
-		os.Exit(1)
+	shutdownTimeout := flag.Duration("shutdown-timeout", 5*time.Second, "max time to drain HTTP connections on shutdown before exiting")
+	configPath := flag.String("config", "closinuf.json", "JSON config file (defaults apply if missing)")
+	logLevel := flag.String("log-level", "info", "log level: debug, info, warn, or error (debug traces the foot switch)")
+	addrFlag := flag.String("addr", "", "listen address, e.g. 0.0.0.0:8080 (overrides the config's addr; default :3000)")
+	flag.Parse()
+	if err := initLogging(*logLevel); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	if err := initPointButton(); err != nil {
-		fmt.Fprintf(os.Stderr, "Fatal: %v\n", err)
-		os.Exit(1)
+
+	if err := loadConfig(*configPath); err != nil {
+		fatal(err)
+	}
+	auth, err := authFromEnv()
+	if err != nil {
+		fatal(err)
+	}
+	addr := cfg().listenAddr()
+	if *addrFlag != "" {
+		addr = *addrFlag
+	}
+	initCaptureCooldown()
+	// ctx stops the poll loop, encoder broadcaster, path sampler, and
+	// autosave writer.
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	if err := initAutosave(ctx); err != nil {
+		fatal(err)
+	}
+	if err := openPointLog(cfg().PointLogPath); err != nil {
+		fatal(err)
+	}
+
+	if err := initEncoders(ctx); err != nil {
+		fatal(err)
+	}
+	startWorker(func() { broadcastEncodersForever(ctx) })
+	initPath(ctx)
+	if useMockBackend() {
+		slog.Info("mock backend: no foot switch (POST /api/mock/replay plays recorded presses)")
+		startup.backend, startup.button = "mock", "disabled"
+	} else if err := startEventRecorder(cfg().RecordEventsPath); err != nil {
+		fatal(err)
+	} else if err := initPointButton(); err != nil {
+		fatal(err)
+	} else {
+		startup.backend, startup.button = "ls7366r", "ok"
 	}
 
 	// Create Fiber app
@@ -25,70 +96,893 @@ func main() {
 		DisableStartupMessage: true,
 	})
 
-	// CORS middleware
-	app.Use(cors.New())
+	// CORS: only localhost and the config's allowedOrigins may call the API from other pages
+	app.Use(cors.New(cors.Config{AllowOriginsFunc: cfg().allowsOrigin}))
+
+	// Credentials for changes (and reads, if configured), from the environment
+	app.Use(requireAuth(auth))
+
+	// Push encoderData JSON to WebSocket clients whenever a count changes
+	app.Use("/ws", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		return c.Next()
+	})
+	app.Get("/ws/encoder", websocket.New(func(conn *websocket.Conn) {
+		updates := encoderUpdates.subscribe()
+		defer encoderUpdates.unsubscribe(updates)
+
+		// Clients only listen; reading is how we notice they went away.
+		gone := make(chan struct{})
+		go func() {
+			defer close(gone)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case msg, ok := <-updates:
+				if !ok {
+					return
+				}
+				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+					return
+				}
+			case <-gone:
+				return
+			}
+		}
+	}))
+
+	// Same updates as Server-Sent Events, for read-only dashboards or curl -N
+	app.Get("/api/encoder/stream", func(c *fiber.Ctx) error {
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			updates := encoderUpdates.subscribe()
+			defer encoderUpdates.unsubscribe(updates)
+			keepAlive := time.NewTicker(sseKeepAlive)
+			defer keepAlive.Stop()
+			for {
+				select {
+				case msg, ok := <-updates:
+					if !ok {
+						return
+					}
+					fmt.Fprintf(w, "data: %s\n\n", msg)
+				case <-keepAlive.C:
+					w.WriteString(": keep-alive\n\n")
+				}
+				// A failed flush means the client disconnected.
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+		return nil
+	})
 
 	// Serve static HTML page
 	app.Get("/", func(c *fiber.Ctx) error {
 		data := getEncoderData()
-		unit := c.Query("unit", "mm") // Default to mm
+		// ?unit= wins so deep links work; else the unit cookie; else mm
+		unit := c.Query("unit", validUnit(c.Cookies("unit")))
+		c.Type("html")
+		return page(data, unit, requestAngle(c), requestTheme(c), requestRefresh(c)).Render(c)
+	})
+
+	// Fullscreen single-axis readout, e.g. /dro/x?unit=in (axis x, xp, y, or z)
+	app.Get("/dro/:axis", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		unit := c.Query("unit", validUnit(c.Cookies("unit")))
+		axis := strings.ToLower(c.Params("axis"))
 		c.Type("html")
-		return page(data, unit).Render(c)
+		v, _ := getEncoderData().axis(enc.label)
+		return droPage(axis, enc.label, v, unit, requestAngle(c), requestTheme(c), requestRefresh(c)).Render(c)
+	})
+
+	// HTMX fragment that refreshes the /dro/:axis readout
+	app.Get("/dro/:axis/htmx", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		axis := strings.ToLower(c.Params("axis"))
+		c.Type("html")
+		refresh := validRefresh(c.QueryInt("refresh"))
+		v, _ := getEncoderData().axis(enc.label)
+		return droFragment(axis, enc.label, v, c.Query("unit", "mm"), requestAngle(c), refresh).Render(c)
+	})
+
+	// Liveness for watchdogs: 503 while any axis's counter reads are failing
+	app.Get("/healthz", func(c *fiber.Ctx) error {
+		report, ok := getHealth()
+		if !ok {
+			c.Status(503)
+		}
+		return c.JSON(report)
 	})
 
+	// Prometheus scrape target, when enabled in the config
+	if cfg().Metrics {
+		app.Get("/metrics", func(c *fiber.Ctx) error {
+			c.Set("Content-Type", metricsContentType)
+			c.Context().SetBodyStreamWriter(writeMetrics)
+			return nil
+		})
+	}
+
 	// HTMX endpoint that returns HTML fragment
 	app.Get("/api/encoder/htmx", func(c *fiber.Ctx) error {
 		data := getEncoderData()
 		unit := c.Query("unit", "mm") // Default to mm
 		c.Type("html")
-		return encoderFragment(data, unit).Render(c)
+		return encoderFragment(data, unit, requestAngle(c), validRefresh(c.QueryInt("refresh"))).Render(c)
+	})
+
+	// JSON encoder data; with since=<version> only axes whose count changed
+	// after that version are included. Clients echo back the returned version.
+	app.Get("/api/encoder", func(c *fiber.Ctx) error {
+		since, err := strconv.ParseUint(c.Query("since", "0"), 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid since"})
+		}
+		version := encoderVersion.Load()
+		axes := map[string]encoderValues{}
+		for _, v := range getEncoderData().Axes {
+			if c.Query("since") == "" || v.Version > since {
+				axes[v.Label] = v
+			}
+		}
+		return c.JSON(fiber.Map{"version": version, "mode": coordMode(), "hold": holdMode.Load(), "axes": axes})
+	})
+
+	// Per-axis scaling (counts/rev, wheel diameter) and related settings
+	app.Get("/api/encoder/config", func(c *fiber.Ctx) error {
+		return c.JSON(getEncoderConfigs())
+	})
+
+	// Adjust an axis's scaling live, e.g. {"axis": "z", "countsPerRev": 4000, "wheelDiameterMm": 20}.
+	// Omitted or zero values are left unchanged; changes are saved to the config file.
+	// backlashCounts or backlashMm (converted at the new scale) sets the backlash; 0 turns it off.
+	app.Post("/api/encoder/config", func(c *fiber.Ctx) error {
+		var req struct {
+			Axis          string   `json:"axis" form:"axis"`
+			CountsPerRev  float64  `json:"countsPerRev" form:"countsPerRev"`
+			WheelDiameter float64  `json:"wheelDiameterMm" form:"wheelDiameterMm"`
+			Backlash      *int     `json:"backlashCounts" form:"backlashCounts"`
+			BacklashMM    *float64 `json:"backlashMm" form:"backlashMm"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		enc, ok := encoderByAxis(req.Axis)
+		if !ok {
+			return c.Status(400).JSON(fiber.Map{"error": "unknown axis " + req.Axis})
+		}
+		if err := enc.setScale(req.CountsPerRev, req.WheelDiameter); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if req.BacklashMM != nil {
+			counts := enc.backlashCounts(*req.BacklashMM)
+			req.Backlash = &counts
+		}
+		if req.Backlash != nil {
+			if err := enc.setBacklash(*req.Backlash); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+		}
+		return c.JSON(enc.config())
+	})
+
+	// Correct an axis's scale error: {"reference": 100, "measured": 99.7} after the
+	// axis read 99.7 over a 100 (any unit) gauge, or {"factor": 1} to reset. Saved to the config file.
+	app.Post("/api/encoder/:axis/calibration", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		var req struct {
+			Reference float64 `json:"reference" form:"reference"`
+			Measured  float64 `json:"measured" form:"measured"`
+			Factor    float64 `json:"factor" form:"factor"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := enc.calibrate(req.Reference, req.Measured, req.Factor); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(enc.config())
+	})
+
+	// Guided calibration: start, move the axis a known distance, then finish with
+	// e.g. ?distance=100&unit=mm. Sets the calibration factor from the counts moved.
+	app.Post("/api/encoder/:axis/calibration/start", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		if err := enc.startCalibration(); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"axis": enc.label, "calibrating": true})
+	})
+	app.Post("/api/encoder/:axis/calibration/finish", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		scale, err := mmPerUnit(c.Query("unit", "mm"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		distance, err := strconv.ParseFloat(c.Query("distance"), 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("invalid distance: %q", c.Query("distance"))})
+		}
+		result, err := enc.finishCalibration(distance * scale)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(result)
+	})
+
+	// Per-axis count rate vs. the LS7366R filter-clock limit (diagnostic)
+	app.Get("/api/encoder/rates", func(c *fiber.Ctx) error {
+		return c.JSON(getEncoderRates())
+	})
+
+	// Per-axis readouts preformatted exactly as the cards show them (thin/LCD clients)
+	app.Get("/api/encoder/formatted", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		if validUnit(unit) != unit {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("unknown unit %q", unit)})
+		}
+		angle := c.Query("angle", "deg")
+		if validAngleUnit(angle) != angle {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("unknown angle unit %q", angle)})
+		}
+		axes := map[string]string{}
+		units := map[string]string{}
+		for _, v := range getEncoderData().Axes {
+			axes[v.Label] = formattedReading(v, unit, angle)
+			units[v.Label] = axisUnit(v, unit)
+		}
+		return c.JSON(fiber.Map{"unit": unit, "axes": axes, "units": units})
+	})
+
+	// One axis's values for minimal clients, e.g. /api/encoder/z?unit=in (axis
+	// x, xp, y, or z). Adds value (the distance in unit, or the angle in angle=
+	// for rotary axes) and the formatted reading.
+	app.Get("/api/encoder/:axis", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{"error": "unknown axis " + c.Params("axis")})
+		}
+		unit := c.Query("unit", "mm")
+		if validUnit(unit) != unit {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("unknown unit %q", unit)})
+		}
+		angle := validAngleUnit(c.Query("angle"))
+		v, _ := getEncoderData().axis(enc.label)
+		r := axisReading{encoderValues: v, Formatted: formattedReading(v, unit, angle)}
+		if v.Angle != nil {
+			deg, _ := degPerAngleUnit(angle)
+			r.Unit, r.Value = angle, *v.Angle/deg
+		} else {
+			r.Unit = resolveUnit(v, unit)
+			mm, _ := mmPerUnit(r.Unit)
+			r.Value = displayDistance(v) / mm
+		}
+		return c.JSON(r)
+	})
+
+	// Reset one named diagnostic counter on an axis (or all axes); never touches position
+	app.Post("/api/reset", func(c *fiber.Ctx) error {
+		if err := resetDiagnostic(c.Query("what"), c.Query("axis")); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.SendStatus(200)
 	})
 
 	// Cycle units endpoint - redirects to page with new unit
 	app.Get("/api/units/cycle", func(c *fiber.Ctx) error {
-		currentUnit := c.Query("unit", "mm")
-		if currentUnit == "" {
-			currentUnit = "mm"
-		}
-
-		// Cycle: mm -> m -> in -> ft -> mm
-		var nextUnit string
-		switch currentUnit {
-		case "mm":
-			nextUnit = "m"
-		case "m":
-			nextUnit = "in"
-		case "in":
-			nextUnit = "ft"
-		case "ft":
-			nextUnit = "mm"
-		default:
-			nextUnit = "mm"
-		}
-
-		// Redirect to page with new unit parameter
+		nextUnit := nextUnit(c.Query("unit", "mm"))
+
+		// Remember the choice for plain reloads, then redirect to page with new unit parameter
+		c.Cookie(&fiber.Cookie{
+			Name:     "unit",
+			Value:    nextUnit,
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			SameSite: "Lax",
+		})
 		c.Set("HX-Redirect", "/?unit="+nextUnit)
 		playBeep()
 		return c.SendStatus(200)
 	})
 
-	// Zero endpoint to reset all encoder counts and clear points
-	app.Post("/api/encoder/zero", func(c *fiber.Ctx) error {
-		if err := clearHardwareCounters(); err != nil {
-			return c.Status(500).SendString(err.Error())
+	// Cycle the angle unit for rotary axes (deg -> rad -> rev), like /api/units/cycle
+	app.Get("/api/units/angle/cycle", func(c *fiber.Ctx) error {
+		next := "deg"
+		switch validAngleUnit(c.Query("angle")) {
+		case "deg":
+			next = "rad"
+		case "rad":
+			next = "rev"
 		}
+		c.Cookie(&fiber.Cookie{
+			Name:     "angle",
+			Value:    next,
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			SameSite: "Lax",
+		})
+		c.Set("HX-Redirect", "/?angle="+next)
+		playBeep()
+		return c.SendStatus(200)
+	})
+
+	// Toggle between the dark and light themes, remembered in a cookie.
+	// The page sends the theme it shows as theme=.
+	app.Post("/api/theme/toggle", func(c *fiber.Ctx) error {
+		next := themeLight
+		if validTheme(c.FormValue("theme")) == themeLight {
+			next = themeDark
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     "theme",
+			Value:    next,
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			SameSite: "Lax",
+		})
+		c.Set("HX-Refresh", "true")
+		return c.SendString(next)
+	})
+
+	// Set the readout refresh interval (refresh=ms), remembered in a cookie
+	app.Post("/api/ui/refresh", func(c *fiber.Ctx) error {
+		refresh, err := strconv.Atoi(c.FormValue("refresh"))
+		if err != nil || validRefresh(refresh) != refresh {
+			return c.Status(400).SendString(fmt.Sprintf("refresh must be one of %v ms", refreshChoices))
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     "refresh",
+			Value:    strconv.Itoa(refresh),
+			Path:     "/",
+			Expires:  time.Now().AddDate(1, 0, 0),
+			SameSite: "Lax",
+		})
+		c.Set("HX-Refresh", "true")
+		return c.SendStatus(200)
+	})
+
+	// Zero endpoint to reset all encoder counts and clear points. In INC
+	// mode it only zeros the incremental reference; the datum and points stay.
+	// /api/points/clear clears points alone.
+	app.Post("/api/encoder/zero", func(c *fiber.Ctx) error {
 		zeroEncoderCounts()
-		clearCapturePoints()
+		if coordMode() == modeAbs {
+			clearCapturePoints()
+		}
+		playBeep()
+		return c.SendStatus(200)
+	})
+
+	// Make a captured point the new origin, e.g. ?index=3 (default the last point):
+	// shifts the X, X', Y, and Z datums by its coordinates. rebase=true moves the
+	// active session's points into the new frame too. Responds in unit (default mm).
+	app.Post("/api/encoder/origin", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		origin, shift, err := setOriginFromPoint(c.QueryInt("index", -1), c.QueryBool("rebase"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		playBeep()
+		return c.JSON(fiber.Map{
+			"unit":    unit,
+			"origin":  xyz{X: origin.x / scale, Y: origin.y / scale, Z: origin.z / scale},
+			"shift":   xyz{X: shift.x / scale, Y: shift.y / scale, Z: shift.z / scale},
+			"rebased": c.QueryBool("rebase"),
+		})
+	})
+
+	// Hold: on=true/false, or toggle when omitted. Freezes the readings (and what
+	// captures record) while counts keep accumulating. Responds with the button label.
+	app.Post("/api/encoder/hold", func(c *fiber.Ctx) error {
+		setHold(c.QueryBool("on", !holdMode.Load()))
+		playBeep()
+		c.Type("html")
+		return g.Text(holdLabel(holdMode.Load())).Render(c)
+	})
+
+	// ABS/INC display mode: mode=abs or mode=inc, or toggle when omitted.
+	// Responds with the new mode's button label for the UI.
+	app.Post("/api/encoder/mode", func(c *fiber.Ctx) error {
+		mode := c.Query("mode")
+		if mode == "" {
+			mode = modeInc
+			if coordMode() == modeInc {
+				mode = modeAbs
+			}
+		}
+		if err := setCoordMode(mode); err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		playBeep()
+		c.Type("html")
+		return g.Text(strings.ToUpper(coordMode())).Render(c)
+	})
+
+	// Zero a single axis at its current position; points are kept
+	app.Post("/api/encoder/:axis/zero", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		enc.preset(0)
+		playBeep()
+		return c.SendStatus(200)
+	})
+
+	// Preset an axis to a known distance, e.g. /api/encoder/x/preset?value=100&unit=mm;
+	// rotary axes take an angle (unit deg, rad, or rev; default deg)
+	app.Post("/api/encoder/:axis/preset", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		rotary := enc.isRotary()
+		var scale float64
+		var err error
+		if rotary {
+			scale, err = degPerAngleUnit(c.Query("unit", "deg"))
+		} else {
+			scale, err = mmPerUnit(c.Query("unit", "mm"))
+		}
+		if err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		value, err := strconv.ParseFloat(c.Query("value"), 64)
+		if err != nil {
+			return c.Status(400).SendString(fmt.Sprintf("invalid value: %q", c.Query("value")))
+		}
+		if rotary {
+			enc.presetAngle(value * scale)
+		} else {
+			enc.preset(value * scale)
+		}
+		playBeep()
+		return c.SendStatus(200)
+	})
+
+	// Reverse an axis that counts backwards (swapAB): on=true/false, or toggle
+	// when omitted; saved to the config
+	app.Post("/api/encoder/:axis/invert", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		on := c.QueryBool("on", !enc.isSwapAB())
+		if err := enc.setSwapAB(on); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"axis": enc.label, "swapAB": on})
+	})
+
+	// Fix an axis's display unit whatever the page's, e.g. ?unit=in for Z;
+	// unit=page follows the page's unit again. Saved to the config.
+	app.Post("/api/encoder/:axis/unit", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		// Fiber's request strings are reused after the handler returns.
+		unit := strings.Clone(c.Query("unit"))
+		if unit == "page" {
+			unit = ""
+		}
+		if err := enc.setUnit(unit); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"axis": enc.label, "unit": unit})
+	})
+
+	// Step an axis's own unit through the Units cycle and back to following
+	// the page; the UI sends it when an axis label is clicked
+	app.Post("/api/encoder/:axis/unit/cycle", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		unit := nextAxisUnit(enc.displayUnit())
+		if err := enc.setUnit(unit); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
 		playBeep()
+		return c.JSON(fiber.Map{"axis": enc.label, "unit": unit})
+	})
+
+	// Lathe diameter mode: on=true/false, or toggle when omitted; saved to the config
+	app.Post("/api/encoder/:axis/diameter", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		on := c.QueryBool("on", !enc.isDiameter())
+		if err := enc.setDiameter(on); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"axis": enc.label, "diameter": on})
+	})
+
+	// Arm homing: the axis's next index pulse sets it to its configured homeCount
+	app.Post("/api/encoder/:axis/home", func(c *fiber.Ctx) error {
+		enc, ok := encoderByAxis(c.Params("axis"))
+		if !ok {
+			return c.Status(404).SendString("unknown axis")
+		}
+		if err := enc.home(); err != nil {
+			return c.Status(409).SendString(err.Error())
+		}
 		return c.SendStatus(200)
 	})
 
+	// Capture the live position; label= optionally names the point
 	app.Post("/api/points/add", func(c *fiber.Ctx) error {
-		addCapturePoint()
+		label, err := requestPointLabel(c)
+		if err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		if !captureAllowed() {
+			return c.Status(429).SendString("capture cooldown")
+		}
+		if err := addCapturePoint(label); err != nil {
+			var tooClose *tooCloseError
+			if errors.As(err, &tooClose) {
+				unit := validUnit(c.Cookies("unit"))
+				return c.Status(409).SendString(fmt.Sprintf("Not captured: only %s from the last point (tolerance %s).",
+					lengthText(tooClose.distance, unit), lengthText(tooClose.tolerance, unit)))
+			}
+			return c.Status(500).SendString(err.Error())
+		}
 		playBeep()
 		return c.SendStatus(200)
 	})
 
+	// Capture the average of several readings, e.g. ?samples=20&unit=in (default
+	// 10, 50 ms apart), with an optional label=; responds with the point and each axis's standard deviation
+	app.Post("/api/points/add-average", func(c *fiber.Ctx) error {
+		n := c.QueryInt("samples", defaultAverageSamples)
+		if n < 2 || n > maxAverageSamples {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("samples must be 2..%d", maxAverageSamples)})
+		}
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		label, err := requestPointLabel(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !captureAllowed() {
+			return c.Status(429).JSON(fiber.Map{"error": "capture cooldown"})
+		}
+		mean, stddev := sampleAverage(n)
+		mean.label = label
+		mean, err = addCapturedPoint(mean)
+		if err != nil {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		playBeep()
+		return c.JSON(fiber.Map{
+			"unit":    unit,
+			"samples": n,
+			"point":   xyz{X: mean.x / scale, Y: mean.y / scale, Z: mean.z / scale},
+			"stddev":  xyz{X: stddev.x / scale, Y: stddev.y / scale, Z: stddev.z / scale},
+		})
+	})
+
+	// Manual point entry - appends a point at explicit x/y/z given in unit (default mm),
+	// with an optional label
+	app.Post("/api/points/add-manual", func(c *fiber.Ctx) error {
+		scale, err := mmPerUnit(c.FormValue("unit", "mm"))
+		if err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		var coords [3]float64
+		for i, name := range []string{"x", "y", "z"} {
+			v, err := strconv.ParseFloat(c.FormValue(name), 64)
+			if err != nil {
+				return c.Status(400).SendString(fmt.Sprintf("invalid %s: %q", name, c.FormValue(name)))
+			}
+			coords[i] = v * scale
+		}
+		label, err := requestPointLabel(c)
+		if err != nil {
+			return c.Status(400).SendString(err.Error())
+		}
+		addManualPoint(coords[0], coords[1], coords[2], label)
+		playBeep()
+		return c.SendStatus(200)
+	})
+
+	// Captured points as JSON, e.g. /api/points?unit=in (default mm)
+	app.Get("/api/points", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"unit": unit, "points": listPoints(scale)})
+	})
+
+	// Distance between the last two points, or points a and b, e.g.
+	// /api/points/distance?a=0&b=3&unit=in (default mm)
+	app.Get("/api/points/distance", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		last := c.Query("a") == "" && c.Query("b") == ""
+		a, errA := strconv.Atoi(c.Query("a"))
+		b, errB := strconv.Atoi(c.Query("b"))
+		if !last && (errA != nil || errB != nil) {
+			return c.Status(400).JSON(fiber.Map{"error": "a and b must both be point indices"})
+		}
+		a, b, d, err := pointDistance(a, b, last)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"unit": unit, "a": a, "b": b, "distance": d / scale})
+	})
+
+	// Last-pair distance readout for the UI
+	app.Get("/api/points/distance/htmx", func(c *fiber.Ctx) error {
+		c.Type("html")
+		if _, _, d, err := pointDistance(0, 0, true); err == nil {
+			return g.Text("Distance: " + lengthText(d, c.Query("unit", "mm"))).Render(c)
+		}
+		return g.Text("Distance: –").Render(c)
+	})
+
+	// Angle at the middle of the last three points, or at b between a and c,
+	// e.g. /api/points/angle?a=0&b=1&c=2, in degrees and radians
+	app.Get("/api/points/angle", func(c *fiber.Ctx) error {
+		last := c.Query("a") == "" && c.Query("b") == "" && c.Query("c") == ""
+		a, errA := strconv.Atoi(c.Query("a"))
+		b, errB := strconv.Atoi(c.Query("b"))
+		v, errC := strconv.Atoi(c.Query("c"))
+		if !last && (errA != nil || errB != nil || errC != nil) {
+			return c.Status(400).JSON(fiber.Map{"error": "a, b and c must all be point indices"})
+		}
+		a, b, v, rad, err := pointAngle(a, b, v, last)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"a": a, "b": b, "c": v, "degrees": rad * 180 / math.Pi, "radians": rad})
+	})
+
+	// Last-three angle readout for the UI
+	app.Get("/api/points/angle/htmx", func(c *fiber.Ctx) error {
+		c.Type("html")
+		if _, _, _, rad, err := pointAngle(0, 0, 0, true); err == nil {
+			return g.Textf("Angle: %.2f°", rad*180/math.Pi).Render(c)
+		}
+		return g.Text("Angle: –").Render(c)
+	})
+
+	// Align to edge: point a becomes the origin and the edge from a to b the X
+	// axis, e.g. ?a=0&b=1 (default the last two points). Exports then use the
+	// aligned frame. Responds with the alignment in unit (default mm).
+	app.Post("/api/points/align", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		al, err := alignToEdge(c.QueryInt("a", -1), c.QueryInt("b", -1))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		playBeep()
+		return c.JSON(alignmentJSON(al, true, unit, scale))
+	})
+
+	// The active session's alignment, e.g. ?unit=in (default mm)
+	app.Get("/api/points/align", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		al, ok := currentAlignment()
+		return c.JSON(alignmentJSON(al, ok, unit, scale))
+	})
+
+	// Drop the alignment; exports go back to machine coordinates
+	app.Delete("/api/points/align", func(c *fiber.Ctx) error {
+		clearAlignment()
+		return c.SendStatus(200)
+	})
+
+	// The points in the aligned frame, like /api/points, e.g. ?unit=in
+	app.Get("/api/points/aligned", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		pts, al := snapshotAlignedPoints()
+		if al == nil {
+			return c.Status(400).JSON(fiber.Map{"error": "session is not aligned; POST /api/points/align first"})
+		}
+		return c.JSON(fiber.Map{"unit": unit, "points": pointList(pts, scale), "align": alignmentJSON(*al, true, unit, scale)})
+	})
+
+	// Extents of the captured points: min, max, and span per axis, e.g.
+	// /api/points/bounds?unit=in (default mm)
+	app.Get("/api/points/bounds", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(boundsIn(unit, scale))
+	})
+
+	// Extents readout (spans) for the UI
+	app.Get("/api/points/bounds/htmx", func(c *fiber.Ctx) error {
+		c.Type("html")
+		lo, hi, n := pointBounds()
+		if n == 0 {
+			return g.Text("Extents: –").Render(c)
+		}
+		unit := c.Query("unit", "mm")
+		return g.Textf("Extents: X %s  Y %s  Z %s",
+			lengthText(hi.x-lo.x, unit), lengthText(hi.y-lo.y, unit), lengthText(hi.z-lo.z, unit)).Render(c)
+	})
+
+	// Scatter plot of the captured points and probe path as an SVG fragment
+	// for the UI, e.g. /api/points/plot?plane=xz&unit=in (default xy, mm)
+	app.Get("/api/points/plot", func(c *fiber.Ctx) error {
+		plane := c.Query("plane", "xy")
+		if _, ok := circlePlanes[plane]; !ok {
+			return c.Status(400).SendString("unknown plane " + plane + " (want xy, xz, or yz)")
+		}
+		c.Type("html")
+		return pointsPlot(plane, c.Query("unit", "mm"), false).Render(c)
+	})
+
+	// The same plot as a standalone SVG image, e.g. /api/path.svg?plane=xz
+	app.Get("/api/path.svg", func(c *fiber.Ctx) error {
+		plane := c.Query("plane", "xy")
+		if _, ok := circlePlanes[plane]; !ok {
+			return c.Status(400).SendString("unknown plane " + plane + " (want xy, xz, or yz)")
+		}
+		c.Set(fiber.HeaderContentType, "image/svg+xml")
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return pointsPlot(plane, c.Query("unit", "mm"), true).Render(c)
+	})
+
+	// Least-squares circle through the captured points, for bore and boss
+	// diameters, e.g. /api/points/circle?plane=xz&unit=in (default xy, mm)
+	app.Get("/api/points/circle", func(c *fiber.Ctx) error {
+		unit := c.Query("unit", "mm")
+		scale, err := mmPerUnit(unit)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		plane := c.Query("plane", "xy")
+		center, r, rms, n, err := fitCircle(plane)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(circleJSON{
+			Unit:     unit,
+			Plane:    plane,
+			Points:   n,
+			Center:   xyz{center.x / scale, center.y / scale, center.z / scale},
+			Radius:   r / scale,
+			Diameter: 2 * r / scale,
+			RMS:      rms / scale,
+		})
+	})
+
+	// Delete one point by its /api/points index
+	app.Delete("/api/points/:index", func(c *fiber.Ctx) error {
+		i, err := strconv.Atoi(c.Params("index"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("invalid index %q", c.Params("index"))})
+		}
+		n, ok := deletePoint(i)
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("no point %d (have %d)", i, n)})
+		}
+		return c.JSON(fiber.Map{"count": n})
+	})
+
+	// Name a point by its /api/points index, e.g. PATCH /api/points/3?label=hole+A;
+	// an empty label clears it
+	app.Patch("/api/points/:index", func(c *fiber.Ctx) error {
+		i, err := strconv.Atoi(c.Params("index"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("invalid index %q", c.Params("index"))})
+		}
+		label, err := requestPointLabel(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		n, ok := setPointLabel(i, label)
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("no point %d (have %d)", i, n)})
+		}
+		return c.JSON(fiber.Map{"index": i, "label": label})
+	})
+
+	// Append points from an uploaded ASC or CSV file (form field "file"),
+	// e.g. to resume a session; unit= gives the file's units (default mm)
+	app.Post("/api/points/import", func(c *fiber.Ctx) error {
+		scale, err := mmPerUnit(c.Query("unit", "mm"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "missing file upload: " + err.Error()})
+		}
+		if fh.Size > maxImportBytes {
+			return c.Status(413).JSON(fiber.Map{"error": fmt.Sprintf("file is %d bytes, limit %d", fh.Size, maxImportBytes)})
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		defer f.Close()
+		pts, skipped, err := readPoints(f, scale)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		appendPoints(pts)
+		slog.Info("imported points", "count", len(pts), "file", fh.Filename, "skipped", skipped)
+		return c.JSON(importResult{Loaded: len(pts), Skipped: skipped})
+	})
+
+	// Undo the last capture; responds with the new count like /api/points/count
+	app.Post("/api/points/undo", func(c *fiber.Ctx) error {
+		n, ok := undoLastPoint()
+		if !ok {
+			return c.Status(400).SendString("No points to undo")
+		}
+		c.Type("html")
+		return g.Text(fmt.Sprintf("Points: %d", n)).Render(c)
+	})
+
+	// Discard the active session's points but keep every datum; responds with
+	// the new count like /api/points/count
+	app.Post("/api/points/clear", func(c *fiber.Ctx) error {
+		clearCapturePoints()
+		c.Type("html")
+		return g.Text("Points: 0").Render(c)
+	})
+
 	app.Get("/api/points/count", func(c *fiber.Ctx) error {
 		c.Type("html")
 		return g.Text(fmt.Sprintf("Points: %d", capturePointCount())).Render(c)
@@ -96,14 +990,12 @@ func main() {
 
 	// Check and save points endpoint - validates points before saving
 	app.Get("/api/points/check-save", func(c *fiber.Ctx) error {
-		filename := c.Query("filename", "points.asc")
-		if filename == "" {
-			filename = "points.asc"
-		}
-		// Add .asc extension if not present
-		if len(filename) < 4 || filename[len(filename)-4:] != ".asc" {
-			filename += ".asc"
+		format := c.Query("format", "asc")
+		f, ok := exportFormats[format]
+		if !ok {
+			return c.Status(400).SendString("unknown format " + format)
 		}
+		filename := exportFilename(c.Query("filename"), f.ext)
 
 		count := capturePointCount()
 
@@ -115,39 +1007,212 @@ func main() {
 
 		// If points exist, clear any error message and redirect to actual save endpoint
 		c.Type("html")
-		c.Set("HX-Redirect", "/api/points/save?filename="+filename)
+		c.Set("HX-Redirect", "/api/points/save?"+exportQuery(c, format, filename))
 		return g.Raw(`<div id="save-error" hx-swap-oob="true" style="display: none;"></div>`).Render(c)
 	})
 
-	// Save points endpoint - saves to ASC file (FreeCAD point cloud format)
+	// Save points endpoint - saves to ASC file (FreeCAD point cloud format),
+	// or another exportFormats entry with format= (e.g. vtk for ParaView)
 	app.Get("/api/points/save", func(c *fiber.Ctx) error {
-		filename := c.Query("filename", "points.asc")
-		if filename == "" {
-			filename = "points.asc"
+		return sendPoints(c, c.Query("format", "asc"))
+	})
+
+	// CSV with a header row for spreadsheets and CMM software; unit= and
+	// timestamps=true apply
+	app.Get("/api/points/export.csv", func(c *fiber.Ctx) error {
+		return sendPoints(c, "csv")
+	})
+
+	// ASCII PLY point cloud for MeshLab / CloudCompare; normals=true applies
+	app.Get("/api/points/export.ply", func(c *fiber.Ctx) error {
+		return sendPoints(c, "ply")
+	})
+
+	// DXF with one POINT entity per capture, for CAD import
+	app.Get("/api/points/export.dxf", func(c *fiber.Ctx) error {
+		return sendPoints(c, "dxf")
+	})
+
+	// G-code program visiting each capture, for drilling or probing the
+	// digitized holes; cycle=, feed=, and safeZ= apply
+	app.Get("/api/points/export.gcode", func(c *fiber.Ctx) error {
+		return sendPoints(c, "gcode")
+	})
+
+	// Measurement sessions; points, undo, zero, and exports act on the active one
+	app.Get("/api/sessions", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"active": activeSessionName(), "sessions": listSessions()})
+	})
+
+	// Session picker fragment, re-fetched by the UI on sessions-changed
+	app.Get("/api/sessions/select", func(c *fiber.Ctx) error {
+		c.Type("html")
+		return sessionPicker(listSessions()).Render(c)
+	})
+
+	// Create a session and switch to it, e.g. POST /api/sessions?name=bracket&unit=in.
+	// The UI's New Session button sends the name in the HX-Prompt header.
+	app.Post("/api/sessions", func(c *fiber.Ctx) error {
+		name := c.FormValue("name", c.Get("HX-Prompt"))
+		info, err := createSession(name, c.FormValue("unit"))
+		if errors.Is(err, errSessionExists) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
-		// Add .asc extension if not present
-		if len(filename) < 4 || filename[len(filename)-4:] != ".asc" {
-			filename += ".asc"
+		c.Set("HX-Trigger", "sessions-changed")
+		return c.Status(201).JSON(info)
+	})
+
+	// Switch the active session (form value name, as sent by the UI picker)
+	app.Post("/api/sessions/active", func(c *fiber.Ctx) error {
+		if err := activateSession(c.FormValue("name")); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
 		}
+		c.Set("HX-Trigger", "sessions-changed")
+		return c.SendStatus(200)
+	})
 
-		ascData, err := capturePointsASC()
+	app.Delete("/api/sessions/:name", func(c *fiber.Ctx) error {
+		err := deleteSession(c.Params("name"))
+		if errors.Is(err, errSessionNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
 		if err != nil {
-			return c.Status(400).JSON(fiber.Map{"error": "No points to save"})
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
 		}
+		c.Set("HX-Trigger", "sessions-changed")
+		return c.SendStatus(200)
+	})
 
-		playBeep()
-		// Set headers for file download
-		c.Set("Content-Type", "text/plain")
-		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
-		return c.SendString(ascData)
+	// The active session with its points, axis scaling, and datums as one
+	// versioned JSON file, to reproduce it later or on another device
+	app.Get("/api/session/export.json", func(c *fiber.Ctx) error {
+		a := snapshotArchive()
+		c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", exportFilename(c.Query("filename", a.Session.Name), ".json")))
+		return c.JSON(a)
 	})
 
+	// Restore an export.json (request body or form field "file"): applies its
+	// axis settings, replaces or creates its session, and switches to it.
+	// offsets=true also restores the datums (same device, no power cycle since).
+	app.Post("/api/session/import", func(c *fiber.Ctx) error {
+		body := io.Reader(bytes.NewReader(c.Body()))
+		if fh, err := c.FormFile("file"); err == nil {
+			if fh.Size > maxImportBytes {
+				return c.Status(413).JSON(fiber.Map{"error": fmt.Sprintf("file is %d bytes, limit %d", fh.Size, maxImportBytes)})
+			}
+			f, err := fh.Open()
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+			defer f.Close()
+			body = f
+		}
+		a, err := readArchive(body)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		res, err := restoreArchive(a, c.QueryBool("offsets"))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		slog.Info("imported session", "session", res.Session.Name, "points", res.Session.Points, "axes", res.Axes)
+		c.Set("HX-Trigger", "sessions-changed")
+		return c.JSON(res)
+	})
+
+	// Capture cooldown shared by the foot switch and web capture, in milliseconds
+	app.Get("/api/config/cooldown", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"cooldownMs": getCaptureCooldown().Milliseconds()})
+	})
+
+	app.Put("/api/config/cooldown", func(c *fiber.Ctx) error {
+		var req struct {
+			CooldownMs int `json:"cooldownMs" form:"cooldownMs"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := setCaptureCooldown(time.Duration(req.CooldownMs) * time.Millisecond); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"cooldownMs": getCaptureCooldown().Milliseconds()})
+	})
+
+	// Counter sampling (and RPM update) interval, and the per-sample RPM
+	// smoothing weight it gives; set pollMs in the config file
+	app.Get("/api/config/poll", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"pollMs":       cfg().poll().Milliseconds(),
+			"rpmSmoothing": cfg().rpmSmoothing(),
+			"rpmAlpha":     cfg().rpmAlpha(),
+		})
+	})
+
+	// Development hooks for driving the mock backend (CLOSINUF_BACKEND=mock)
+	if mock, ok := counters.(*mockCounters); ok {
+		// Turn an axis by counts, e.g. POST /api/mock/move?axis=x&counts=2400
+		app.Post("/api/mock/move", func(c *fiber.Ctx) error {
+			enc, ok := encoderByAxis(c.Query("axis"))
+			if !ok {
+				return c.Status(400).SendString("unknown axis")
+			}
+			mock.move(enc.chip, int32(c.QueryInt("counts")))
+			return c.SendStatus(200)
+		})
+
+		// Simulate an axis passing its index mark, e.g. POST /api/mock/index?axis=x
+		app.Post("/api/mock/index", func(c *fiber.Ctx) error {
+			enc, ok := encoderByAxis(c.Query("axis"))
+			if !ok {
+				return c.Status(400).SendString("unknown axis")
+			}
+			mock.pulseIndex(enc.chip)
+			return c.SendStatus(200)
+		})
+
+		// Replay a GPIO event recording (request body) through the foot-switch
+		// logic in real time, as if the switch were wired up
+		app.Post("/api/mock/replay", func(c *fiber.Ctx) error {
+			events, err := readEvents(bytes.NewReader(c.Body()))
+			if err != nil {
+				return c.Status(400).SendString(err.Error())
+			}
+			if !replaying.CompareAndSwap(false, true) {
+				return c.Status(409).SendString("a replay is already running")
+			}
+			startWorker(func() {
+				defer replaying.Store(false)
+				replayEvents(ctx, events)
+			})
+			var length time.Duration
+			if len(events) > 0 {
+				length = events[len(events)-1].at
+			}
+			return c.JSON(fiber.Map{"events": len(events), "seconds": length.Seconds()})
+		})
+
+		// Start or stop the simulated motion, e.g. POST /api/mock/motion?on=false
+		app.Post("/api/mock/motion", func(c *fiber.Ctx) error {
+			mock.setMotion(c.QueryBool("on", true))
+			return c.SendStatus(200)
+		})
+	}
+
+	// Listen before starting so a bad or busy address fails right away, and
+	// so the message shows the actual address (e.g. the port picked for :0).
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		fatal(err)
+	}
+
 	// Start server in goroutine
 	go func() {
-		os.Stdout.WriteString("Server is running, listening on :3000\n")
-		if err := app.Listen(":3000"); err != nil {
-			os.Stderr.WriteString("Failed to start server: " + err.Error() + "\n")
-			os.Exit(1)
+		slog.Info("server is running", "addr", ln.Addr().String())
+		if err := app.Listener(ln); err != nil {
+			fatal(fmt.Errorf("server: %w", err))
 		}
 	}()
 
@@ -156,5 +1221,199 @@ func main() {
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	<-sig
 
-	os.Stdout.WriteString("\nShutting down...\n")
+	slog.Info("shutting down")
+	encoderUpdates.close()
+	err = app.ShutdownWithTimeout(*shutdownTimeout)
+	if err != nil {
+		// Streaming clients that never finish would otherwise hold the process up.
+		slog.Error("shutdown timed out, exiting anyway", "after", *shutdownTimeout, "err", err,
+			"openConnections", app.Server().GetOpenConnectionsCount())
+	}
+	// With no handlers left running, release the GPIO lines and SPI device
+	// so a restarted instance can claim them straight away.
+	closePointButton()
+	stopEventRecorder()
+	// Stop the workers only now, so points captured while draining still
+	// reach the final autosave.
+	stopWorkers()
+	workers.Wait()
+	closePointLog()
+	closeCounters()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// sendPoints streams the captured points as a download in format, named
+// from the filename query parameter. It is shared by /api/points/save and
+// the /api/points/export.* routes.
+func sendPoints(c *fiber.Ctx, format string) error {
+	f, ok := exportFormats[format]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "Unknown format " + format})
+	}
+	filename := exportFilename(c.Query("filename"), f.ext)
+	opts, err := exportOptionsFromQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if opts.closeLoop && !f.closes {
+		return c.Status(400).JSON(fiber.Map{"error": "close=true only applies to vtk and dxf, which draw a line through the points"})
+	}
+	// An aligned session exports in its aligned frame unless frame=machine.
+	var pts []point
+	var al *alignment
+	switch c.Query("frame", "aligned") {
+	case "aligned":
+		pts, al = snapshotAlignedPoints()
+	case "machine":
+		pts = snapshotPoints()
+	default:
+		return c.Status(400).JSON(fiber.Map{"error": "frame must be aligned or machine"})
+	}
+	if len(pts) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "No points to save"})
+	}
+	opts.align = al
+
+	playBeep()
+	// Set headers for file download; rows stream from the snapshot so the
+	// whole file is never built in memory.
+	c.Set("Content-Type", f.contentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := f.write(w, pts, opts); err != nil {
+			slog.Error("save", "file", filename, "err", err)
+		}
+	})
+	return nil
+}
+
+// exportFilename defaults an empty name to "points" and makes it end in ext,
+// replacing the UI's default .asc extension when another format is requested.
+func exportFilename(name, ext string) string {
+	if name == "" {
+		name = "points"
+	}
+	if strings.HasSuffix(name, ext) {
+		return name
+	}
+	return strings.TrimSuffix(name, ".asc") + ext
+}
+
+// exportQuery rebuilds the save endpoint's query string, forwarding the
+// export options from the check-save request.
+func exportQuery(c *fiber.Ctx, format, filename string) string {
+	q := url.Values{}
+	q.Set("format", format)
+	q.Set("filename", filename)
+	for _, key := range []string{"close", "normals", "k", "header", "operator", "unit", "timestamps", "precision", "cycle", "feed", "safeZ", "frame"} {
+		if v := c.Query(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	return q.Encode()
+}
+
+// exportOptionsFromQuery reads close, normals, k (normal neighbors), header,
+// operator, unit, timestamps, precision, and the G-code cycle, feed, and
+// safeZ (both in mm). Precision is clamped to its
+// range and a non-numeric one means the default, rather than failing the
+// download.
+func exportOptionsFromQuery(c *fiber.Ctx) (exportOptions, error) {
+	opts := exportOptions{
+		closeLoop:  c.QueryBool("close"),
+		header:     c.QueryBool("header", cfg().ExportHeader),
+		unit:       c.Query("unit", "mm"),
+		timestamps: c.QueryBool("timestamps"),
+		cycle:      c.Query("cycle", "move"),
+		safeZ:      math.NaN(),
+		feed:       defaultGCodeFeed,
+	}
+	operator, err := validateOperator(c.Query("operator"))
+	if err != nil {
+		return opts, err
+	}
+	opts.operator = strings.Clone(operator) // written after the handler returns
+	if !gcodeCycles[opts.cycle] {
+		return opts, fmt.Errorf("cycle must be move or drill")
+	}
+	if v := c.Query("feed"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(f > 0) || math.IsInf(f, 0) {
+			return opts, fmt.Errorf("feed must be a positive number of mm/min")
+		}
+		opts.feed = f
+	}
+	if v := c.Query("safeZ"); v != "" {
+		z, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(z) || math.IsInf(z, 0) {
+			return opts, fmt.Errorf("safeZ must be a number of mm")
+		}
+		opts.safeZ = z
+	}
+	p := c.QueryInt("precision", defaultExportPrecision)
+	opts.precision = min(max(p, minExportPrecision), maxExportPrecision)
+	scale, err := mmPerUnit(opts.unit)
+	if err != nil {
+		return opts, err
+	}
+	opts.scale = scale
+	if c.QueryBool("normals") {
+		k := c.QueryInt("k", defaultNormalNeighbors)
+		opts.normalsK = min(max(k, minNormalNeighbors), maxNormalNeighbors)
+	}
+	return opts, nil
+}
+
+// alignmentJSON reports an alignment with its origin in unit and its angle
+// in degrees and radians; just {"aligned": false} when ok is false.
+func alignmentJSON(al alignment, ok bool, unit string, scale float64) fiber.Map {
+	if !ok {
+		return fiber.Map{"aligned": false}
+	}
+	return fiber.Map{
+		"aligned": true,
+		"unit":    unit,
+		"origin":  fiber.Map{"x": al.OriginX / scale, "y": al.OriginY / scale},
+		"degrees": al.Angle * 180 / math.Pi,
+		"radians": al.Angle,
+	}
+}
+
+// requestPointLabel reads an optional point label from the label query or
+// form value (a JSON body's "label" for PATCH).
+func requestPointLabel(c *fiber.Ctx) (string, error) {
+	label := c.Query("label", c.FormValue("label"))
+	if label == "" && strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+		var req struct {
+			Label string `json:"label"`
+		}
+		if err := c.BodyParser(&req); err != nil {
+			return "", err
+		}
+		label = req.Label
+	}
+	label, err := validatePointLabel(label)
+	// Fiber's request strings are reused after the handler returns.
+	return strings.Clone(label), err
+}
+
+// requestTheme picks the page theme: ?theme= wins, so a kiosk can force
+// one; else the theme cookie; else dark.
+func requestTheme(c *fiber.Ctx) string {
+	return validTheme(c.Query("theme", c.Cookies("theme")))
+}
+
+// requestAngle picks the angle unit for rotary axes: ?angle= wins, else the
+// angle cookie, else degrees.
+func requestAngle(c *fiber.Ctx) string {
+	return validAngleUnit(c.Query("angle", c.Cookies("angle")))
+}
+
+// requestRefresh picks the readout refresh interval in ms: ?refresh= wins,
+// else the refresh cookie, else the default.
+func requestRefresh(c *fiber.Ctx) int {
+	cookie, _ := strconv.Atoi(c.Cookies("refresh"))
+	return validRefresh(c.QueryInt("refresh", cookie))
 }
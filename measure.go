@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errTooFewPoints = errors.New("need at least two points")
+
+// pointDistance returns the straight-line 3D distance in mm between points
+// a and b (capture order) of the active session. With last set it ignores
+// a and b and measures between the last two points, returning their indices.
+func pointDistance(a, b int, last bool) (int, int, float64, error) {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	pts := active.points
+	if len(pts) < 2 {
+		return 0, 0, 0, errTooFewPoints
+	}
+	if last {
+		a, b = len(pts)-2, len(pts)-1
+	}
+	for _, i := range []int{a, b} {
+		if i < 0 || i >= len(pts) {
+			return 0, 0, 0, fmt.Errorf("point %d out of range (0..%d)", i, len(pts)-1)
+		}
+	}
+	return a, b, math.Sqrt(dist2(pts[a], pts[b])), nil
+}
+
+// collinearTolerance is the smallest sine of an angle pointAngle measures;
+// arms closer to a straight line than this (about 0.0006°) have no corner.
+const collinearTolerance = 1e-5
+
+// pointAngle returns the angle in radians at point b between the lines to
+// points a and c (capture order) of the active session, 0..π. With last set
+// it ignores the indices and uses the last three points, returning them.
+func pointAngle(a, b, c int, last bool) (int, int, int, float64, error) {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	pts := active.points
+	if len(pts) < 3 {
+		return 0, 0, 0, 0, errors.New("need at least three points")
+	}
+	if last {
+		a, b, c = len(pts)-3, len(pts)-2, len(pts)-1
+	}
+	for _, i := range []int{a, b, c} {
+		if i < 0 || i >= len(pts) {
+			return 0, 0, 0, 0, fmt.Errorf("point %d out of range (0..%d)", i, len(pts)-1)
+		}
+	}
+	u := [3]float64{pts[a].x - pts[b].x, pts[a].y - pts[b].y, pts[a].z - pts[b].z}
+	v := [3]float64{pts[c].x - pts[b].x, pts[c].y - pts[b].y, pts[c].z - pts[b].z}
+	lu, lv := math.Hypot(math.Hypot(u[0], u[1]), u[2]), math.Hypot(math.Hypot(v[0], v[1]), v[2])
+	if lu == 0 || lv == 0 {
+		return a, b, c, 0, fmt.Errorf("points %d, %d and %d: the corner coincides with an end point", a, b, c)
+	}
+	cross := math.Hypot(math.Hypot(u[1]*v[2]-u[2]*v[1], u[2]*v[0]-u[0]*v[2]), u[0]*v[1]-u[1]*v[0])
+	if cross/(lu*lv) < collinearTolerance {
+		return a, b, c, 0, fmt.Errorf("points %d, %d and %d are collinear: no angle", a, b, c)
+	}
+	return a, b, c, math.Atan2(cross, u[0]*v[0]+u[1]*v[1]+u[2]*v[2]), nil
+}
+
+// xyz is a coordinate triple in a display unit.
+type xyz struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// boundsJSON is the answer to /api/points/bounds. Min, Max, and Span are
+// omitted when there are no points.
+type boundsJSON struct {
+	Unit  string `json:"unit"`
+	Count int    `json:"count"`
+	Min   *xyz   `json:"min,omitempty"`
+	Max   *xyz   `json:"max,omitempty"`
+	Span  *xyz   `json:"span,omitempty"`
+}
+
+// pointBounds returns the axis-aligned extents (mm) of the active session's
+// points, found in one pass under the lock. n is 0 when there are no points.
+func pointBounds() (lo, hi point, n int) {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	for i, p := range active.points {
+		if i == 0 {
+			lo, hi = p, p
+			continue
+		}
+		lo.x, hi.x = min(lo.x, p.x), max(hi.x, p.x)
+		lo.y, hi.y = min(lo.y, p.y), max(hi.y, p.y)
+		lo.z, hi.z = min(lo.z, p.z), max(hi.z, p.z)
+	}
+	return lo, hi, len(active.points)
+}
+
+// boundsIn converts pointBounds to unit, one unit being scale mm.
+func boundsIn(unit string, scale float64) boundsJSON {
+	lo, hi, n := pointBounds()
+	b := boundsJSON{Unit: unit, Count: n}
+	if n == 0 {
+		return b
+	}
+	b.Min = &xyz{lo.x / scale, lo.y / scale, lo.z / scale}
+	b.Max = &xyz{hi.x / scale, hi.y / scale, hi.z / scale}
+	b.Span = &xyz{(hi.x - lo.x) / scale, (hi.y - lo.y) / scale, (hi.z - lo.z) / scale}
+	return b
+}
+
+// circlePlanes maps the plane= choices for /api/points/circle to the point
+// coordinates that span them.
+var circlePlanes = map[string]func(point) (u, v, w float64){
+	"xy": func(p point) (float64, float64, float64) { return p.x, p.y, p.z },
+	"xz": func(p point) (float64, float64, float64) { return p.x, p.z, p.y },
+	"yz": func(p point) (float64, float64, float64) { return p.y, p.z, p.x },
+}
+
+// circleJSON is the answer to /api/points/circle. Center's out-of-plane
+// coordinate is the mean of the points' coordinates on that axis.
+type circleJSON struct {
+	Unit     string  `json:"unit"`
+	Plane    string  `json:"plane"`
+	Points   int     `json:"points"`
+	Center   xyz     `json:"center"`
+	Radius   float64 `json:"radius"`
+	Diameter float64 `json:"diameter"`
+	RMS      float64 `json:"rms"` // root-mean-square of each point's distance from the circle
+}
+
+// fitCircle fits a circle to the active session's points projected onto
+// plane, in mm. It minimizes the algebraic error (Kåsa's method) on
+// coordinates centered on their mean, which is exact for points on a circle
+// and close to the geometric fit for a short arc of noisy points.
+func fitCircle(plane string) (center point, radius, rms float64, n int, err error) {
+	proj, ok := circlePlanes[plane]
+	if !ok {
+		return point{}, 0, 0, 0, fmt.Errorf("unknown plane %q (want xy, xz, or yz)", plane)
+	}
+	pts := snapshotPoints()
+	n = len(pts)
+	if n < 3 {
+		return point{}, 0, 0, n, errors.New("need at least three points")
+	}
+	var mu, mv, mw float64
+	for _, p := range pts {
+		u, v, w := proj(p)
+		mu, mv, mw = mu+u, mv+v, mw+w
+	}
+	mu, mv, mw = mu/float64(n), mv/float64(n), mw/float64(n)
+
+	var suu, svv, suv, suuu, svvv, suvv, svuu float64
+	for _, p := range pts {
+		u, v, _ := proj(p)
+		u, v = u-mu, v-mv
+		suu += u * u
+		svv += v * v
+		suv += u * v
+		suuu += u * u * u
+		svvv += v * v * v
+		suvv += u * v * v
+		svuu += v * u * u
+	}
+	det := suu*svv - suv*suv
+	if det <= 1e-12*suu*svv { // det is 0 when the points lie on a line
+		return point{}, 0, 0, n, errors.New("points are collinear in that plane")
+	}
+	bu, bv := (suuu+suvv)/2, (svvv+svuu)/2
+	cu := (bu*svv - bv*suv) / det
+	cv := (bv*suu - bu*suv) / det
+	radius = math.Sqrt(cu*cu + cv*cv + (suu+svv)/float64(n))
+
+	var sumSq float64
+	for _, p := range pts {
+		u, v, _ := proj(p)
+		sumSq += sq(math.Hypot(u-mu-cu, v-mv-cv) - radius)
+	}
+	rms = math.Sqrt(sumSq / float64(n))
+
+	// Map (u, v, w) back to x, y, z.
+	cu, cv = cu+mu, cv+mv
+	switch plane {
+	case "xy":
+		center = point{x: cu, y: cv, z: mw}
+	case "xz":
+		center = point{x: cu, y: mw, z: cv}
+	case "yz":
+		center = point{x: mw, y: cu, z: cv}
+	}
+	return center, radius, rms, n, nil
+}
+
+func sq(v float64) float64 { return v * v }
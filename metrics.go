@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Totals since startup for /metrics.
+var (
+	pointsCaptured atomic.Uint64 // points stored by the web button, foot switch, or manual entry
+	buttonPresses  atomic.Uint64 // debounced foot-switch presses
+)
+
+// metricsContentType is the Prometheus text exposition format, version 0.0.4.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// writeMetrics writes per-axis readings and capture totals in the Prometheus
+// text format. It is small enough to write by hand, which keeps the client
+// library out of the build.
+func writeMetrics(w *bufio.Writer) {
+	data := getEncoderData()
+	axes := data.Axes
+	perAxis := func(name, typ, help string, value func(encoderValues) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, v := range axes {
+			fmt.Fprintf(w, "%s{axis=\"%s\"} %g\n", name, escapeLabel(v.Label), value(v))
+		}
+	}
+	single := func(name, typ, help string, value float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, typ, name, value)
+	}
+
+	perAxis("closinuf_encoder_count", "gauge", "Counts from the datum.",
+		func(v encoderValues) float64 { return float64(v.Count) })
+	perAxis("closinuf_encoder_raw_count", "gauge", "Accumulated hardware count.",
+		func(v encoderValues) float64 { return float64(v.RawCount) })
+	perAxis("closinuf_encoder_distance_mm", "gauge", "Displayed distance in mm.",
+		func(v encoderValues) float64 { return v.Distance })
+	perAxis("closinuf_encoder_rpm", "gauge", "Smoothed wheel speed in revolutions per minute.",
+		func(v encoderValues) float64 { return v.RPM })
+	perAxis("closinuf_encoder_velocity_mm_per_second", "gauge", "Smoothed wheel speed in mm/s.",
+		func(v encoderValues) float64 { return v.Velocity })
+	perAxis("closinuf_encoder_travel_mm_total", "counter", "Distance moved either way since the last travel reset, in mm.",
+		func(v encoderValues) float64 { return v.TotalTravel })
+	perAxis("closinuf_encoder_read_errors_total", "counter", "Failed LS7366R counter reads since the last errors reset.",
+		func(v encoderValues) float64 { return float64(v.Errors) })
+
+	single("closinuf_points", "gauge", "Points in the active session.", float64(capturePointCount()))
+	single("closinuf_points_captured_total", "counter", "Points captured with the web button or foot switch, or entered by hand.", float64(pointsCaptured.Load()))
+	single("closinuf_button_presses_total", "counter", "Debounced foot-switch presses.", float64(buttonPresses.Load()))
+}
+
+// escapeLabel escapes a Prometheus label value.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
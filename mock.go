@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// mockCounters simulates the LS7366R bank for development off-Pi. Each
+// counter is a programmable offset plus, unless disabled, a slow simulated
+// motion so the web UI shows movement.
+type mockCounters struct {
+	mu     sync.Mutex
+	start  time.Time
+	motion bool
+	offset []int32
+	edges  []int // quadrature edges counted per cycle, scaling the motion
+
+	armed   []bool  // armIndex called, waiting for pulseIndex
+	fired   []bool  // pulseIndex loaded the count
+	preload []int32 // count an armed pulse loads
+}
+
+func newMockCounters(n int) *mockCounters {
+	return &mockCounters{
+		start:   time.Now(),
+		motion:  true,
+		offset:  make([]int32, n),
+		edges:   make([]int, n),
+		armed:   make([]bool, n),
+		fired:   make([]bool, n),
+		preload: make([]int32, n),
+	}
+}
+
+// simulated returns the motion component of chip's count at t: X and X' sweep
+// together (X' lagging slightly, like a racked gantry), Y traces a circle with
+// X, and Z bobs slowly.
+func (m *mockCounters) simulated(chip int, t time.Time) int32 {
+	if !m.motion {
+		return 0
+	}
+	const amplitude = 200.0 / defaultWheelCircumference * defaultCountsPerRev // 200 mm in counts
+	phase := 2 * math.Pi * t.Sub(m.start).Seconds() / 20
+	var v float64
+	switch chip {
+	case 0:
+		v = amplitude * math.Sin(phase)
+	case 1:
+		v = amplitude * math.Sin(phase-0.01)
+	case 2:
+		v = amplitude * (1 - math.Cos(phase))
+	case 3:
+		v = amplitude / 4 * math.Sin(phase/3)
+	}
+	return int32(v * float64(m.edges[chip]) / 4)
+}
+
+func (m *mockCounters) readCounter(chip int) (int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offset[chip] + m.simulated(chip, time.Now()), nil
+}
+
+func (m *mockCounters) armIndex(chip int, count int32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.armed[chip], m.fired[chip], m.preload[chip] = true, false, count
+	return nil
+}
+
+func (m *mockCounters) indexFired(chip int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fired := m.fired[chip]
+	m.fired[chip] = false
+	return fired, nil
+}
+
+// pulseIndex simulates chip's encoder passing its index mark.
+func (m *mockCounters) pulseIndex(chip int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.armed[chip] {
+		return
+	}
+	m.offset[chip] = m.preload[chip] - m.simulated(chip, time.Now())
+	m.armed[chip], m.fired[chip] = false, true
+}
+
+func (m *mockCounters) close() {}
+
+// move adds delta counts to chip, as if the encoder had turned.
+func (m *mockCounters) move(chip int, delta int32) {
+	m.mu.Lock()
+	m.offset[chip] += delta
+	m.mu.Unlock()
+}
+
+// setMotion turns the simulated motion on or off; the count stays where it is.
+func (m *mockCounters) setMotion(on bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Fold the current motion into the offsets so the count doesn't jump.
+	now := time.Now()
+	for chip := range m.offset {
+		m.offset[chip] += m.simulated(chip, now)
+	}
+	m.motion = on
+	for chip := range m.offset {
+		m.offset[chip] -= m.simulated(chip, now)
+	}
+}
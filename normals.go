@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	defaultNormalNeighbors = 8
+	minNormalNeighbors     = 3
+	maxNormalNeighbors     = 64
+)
+
+// estimateNormals returns a unit normal per point from a PCA over its k
+// nearest neighbors (the point included): the normal is the eigenvector of
+// the neighborhood covariance with the smallest eigenvalue. Normals are
+// oriented towards +Z, which suits surfaces probed from above. It returns
+// nil when there are too few points to fit a plane.
+func estimateNormals(pts []point, k int) [][3]float64 {
+	if k > len(pts) {
+		k = len(pts)
+	}
+	if k < minNormalNeighbors {
+		return nil
+	}
+	normals := make([][3]float64, len(pts))
+	idx := make([]int, len(pts))
+	for i, p := range pts {
+		for j := range idx {
+			idx[j] = j
+		}
+		sort.Slice(idx, func(a, b int) bool {
+			return dist2(p, pts[idx[a]]) < dist2(p, pts[idx[b]])
+		})
+		normals[i] = planeNormal(pts, idx[:k])
+	}
+	return normals
+}
+
+func dist2(a, b point) float64 {
+	dx, dy, dz := a.x-b.x, a.y-b.y, a.z-b.z
+	return dx*dx + dy*dy + dz*dz
+}
+
+// planeNormal fits a plane to pts[idx] and returns its unit normal.
+func planeNormal(pts []point, idx []int) [3]float64 {
+	var c [3]float64
+	for _, i := range idx {
+		c[0] += pts[i].x
+		c[1] += pts[i].y
+		c[2] += pts[i].z
+	}
+	n := float64(len(idx))
+	c[0], c[1], c[2] = c[0]/n, c[1]/n, c[2]/n
+
+	var cov [3][3]float64
+	for _, i := range idx {
+		d := [3]float64{pts[i].x - c[0], pts[i].y - c[1], pts[i].z - c[2]}
+		for r := 0; r < 3; r++ {
+			for s := 0; s < 3; s++ {
+				cov[r][s] += d[r] * d[s]
+			}
+		}
+	}
+
+	vals, vecs := jacobiEigen3(cov)
+	m := 0
+	for i := 1; i < 3; i++ {
+		if vals[i] < vals[m] {
+			m = i
+		}
+	}
+	nv := [3]float64{vecs[0][m], vecs[1][m], vecs[2][m]}
+	if nv[2] < 0 {
+		nv = [3]float64{-nv[0], -nv[1], -nv[2]}
+	}
+	return nv
+}
+
+// jacobiEigen3 diagonalizes a symmetric 3×3 matrix with cyclic Jacobi
+// rotations. It returns the eigenvalues and the eigenvectors as columns.
+func jacobiEigen3(a [3][3]float64) ([3]float64, [3][3]float64) {
+	v := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	for sweep := 0; sweep < 50; sweep++ {
+		off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < 2; p++ {
+			for q := p + 1; q < 3; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, theta) / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				cs := 1 / math.Sqrt(t*t+1)
+				sn := t * cs
+				for k := 0; k < 3; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = cs*akp - sn*akq
+					a[k][q] = sn*akp + cs*akq
+				}
+				for k := 0; k < 3; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = cs*apk - sn*aqk
+					a[q][k] = sn*apk + cs*aqk
+				}
+				for k := 0; k < 3; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = cs*vkp - sn*vkq
+					v[k][q] = sn*vkp + cs*vkq
+				}
+			}
+		}
+	}
+	return [3]float64{a[0][0], a[1][1], a[2][2]}, v
+}
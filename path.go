@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pathTrace is a ring buffer of recent probe positions (mm) for the path
+// plot. Samples equal to the previous one are dropped, so the buffer holds
+// motion rather than time spent standing still.
+type pathTrace struct {
+	mu    sync.Mutex
+	buf   []point
+	start int    // oldest sample
+	n     int    // samples held
+	gen   uint64 // bumped by clear
+}
+
+// probePath is the live trace drawn by /api/path.svg and the UI plot.
+var probePath = newPathTrace(defaultPathLength)
+
+func newPathTrace(size int) *pathTrace {
+	return &pathTrace{buf: make([]point, size)}
+}
+
+// add appends p, overwriting the oldest sample once the buffer is full.
+func (t *pathTrace) add(p point) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.push(p)
+}
+
+// push is add's body. Caller holds t.mu.
+func (t *pathTrace) push(p point) {
+	if t.n > 0 {
+		last := t.buf[(t.start+t.n-1)%len(t.buf)]
+		if last.x == p.x && last.y == p.y && last.z == p.z {
+			return
+		}
+	}
+	if t.n < len(t.buf) {
+		t.buf[(t.start+t.n)%len(t.buf)] = p
+		t.n++
+		return
+	}
+	t.buf[t.start] = p
+	t.start = (t.start + 1) % len(t.buf)
+}
+
+// clear drops every sample. The datum, scale, or direction of an axis
+// moved, so the trace no longer lies in the coordinates captures use.
+func (t *pathTrace) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.start, t.n = 0, 0
+	t.gen++
+}
+
+// generation counts the clears so far; see addSince.
+func (t *pathTrace) generation() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gen
+}
+
+// addSince is add for a sample taken when generation returned gen. It drops
+// p if the trace was cleared since, as p was read in the old coordinates.
+func (t *pathTrace) addSince(gen uint64, p point) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.gen == gen {
+		t.push(p)
+	}
+}
+
+// snapshot returns the samples oldest first.
+func (t *pathTrace) snapshot() []point {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]point, t.n)
+	for i := range out {
+		out[i] = t.buf[(t.start+i)%len(t.buf)]
+	}
+	return out
+}
+
+// initPath sizes the trace from config and starts sampling into it.
+func initPath(ctx context.Context) {
+	probePath = newPathTrace(cfg().pathLength())
+	startWorker(func() { samplePathForever(ctx, cfg().pathSample()) })
+}
+
+// samplePathForever records the probe position every interval until ctx is
+// cancelled.
+func samplePathForever(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		gen := probePath.generation()
+		probePath.addSince(gen, livePoint())
+	}
+}
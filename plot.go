@@ -0,0 +1,147 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	g "maragu.dev/gomponents"
+	. "maragu.dev/gomponents/html"
+)
+
+// Size of the points plot in SVG user units; the page scales it to fit.
+const (
+	plotWidth  = 480
+	plotHeight = 320
+	plotMargin = 56
+)
+
+// Plot colours. They are set as attributes rather than page CSS so that
+// /api/path.svg looks the same when opened on its own.
+const (
+	plotColor = "#00ff41"
+	plotDim   = "rgba(0, 255, 65, 0.35)"
+	plotBg    = "#0a0a0a"
+)
+
+// pointsPlot draws the active session's points and the recent probe path,
+// projected onto plane (a circlePlanes key), as an SVG scatter plot. Both
+// axes share one scale fitted to what is drawn, so shapes keep their
+// proportions; the extents box is labelled with its min and max in unit.
+// The newest point is a ring and the probe's current position a cross.
+// standalone adds what a separate .svg file needs: the SVG namespace and a
+// background.
+func pointsPlot(plane, unit string, standalone bool) g.Node {
+	proj := circlePlanes[plane]
+	pts := snapshotPoints()
+	trace := probePath.snapshot()
+	hName, vName := strings.ToUpper(plane[:1]), strings.ToUpper(plane[1:])
+
+	frame := []g.Node{
+		g.Attr("viewBox", "0 0 "+strconv.Itoa(plotWidth)+" "+strconv.Itoa(plotHeight)),
+		Class("points-plot"),
+		g.Attr("role", "img"),
+		g.Attr("aria-label", hName+vName+" plot of captured points"),
+	}
+	if standalone {
+		frame = append(frame,
+			g.Attr("xmlns", "http://www.w3.org/2000/svg"),
+			g.Attr("width", strconv.Itoa(plotWidth)), g.Attr("height", strconv.Itoa(plotHeight)),
+			g.El("rect", g.Attr("width", "100%"), g.Attr("height", "100%"), g.Attr("fill", plotBg)),
+		)
+	}
+	all := append(append([]point(nil), pts...), trace...)
+	if len(all) == 0 {
+		return SVG(g.Group(frame), plotText(plotWidth/2, plotHeight/2, "middle", "No points"))
+	}
+
+	umin, vmin, _ := proj(all[0])
+	umax, vmax := umin, vmin
+	for _, p := range all[1:] {
+		u, v, _ := proj(p)
+		umin, umax = min(umin, u), max(umax, u)
+		vmin, vmax = min(vmin, v), max(vmax, v)
+	}
+	// One scale for both axes; a zero span (one point, or points on a line
+	// parallel to an axis) doesn't limit it.
+	scale := math.Inf(1)
+	if umax > umin {
+		scale = min(scale, (plotWidth-2*plotMargin)/(umax-umin))
+	}
+	if vmax > vmin {
+		scale = min(scale, (plotHeight-2*plotMargin)/(vmax-vmin))
+	}
+	if math.IsInf(scale, 1) {
+		scale = 1
+	}
+	uc, vc := (umin+umax)/2, (vmin+vmax)/2
+	x := func(u float64) float64 { return plotWidth/2 + (u-uc)*scale }
+	y := func(v float64) float64 { return plotHeight/2 - (v-vc)*scale } // SVG y grows down
+
+	nodes := []g.Node{
+		g.El("rect",
+			plotAttr("x", x(umin)), plotAttr("y", y(vmax)),
+			plotAttr("width", x(umax)-x(umin)), plotAttr("height", y(vmin)-y(vmax)),
+			g.Attr("fill", "none"), g.Attr("stroke", plotDim), g.Attr("stroke-dasharray", "4 4"),
+		),
+		plotText(x(umin), y(vmin)+16, "start", hName+" "+lengthText(umin, unit)),
+		plotText(4, y(vmin), "start", vName+" "+lengthText(vmin, unit)),
+	}
+	if umax > umin {
+		nodes = append(nodes, plotText(x(umax), y(vmin)+16, "end", lengthText(umax, unit)))
+	}
+	if vmax > vmin {
+		nodes = append(nodes, plotText(4, y(vmax)+12, "start", lengthText(vmax, unit)))
+	}
+
+	if len(trace) > 0 {
+		coords := make([]string, len(trace))
+		for i, p := range trace {
+			u, v, _ := proj(p)
+			coords[i] = plotNum(x(u)) + "," + plotNum(y(v))
+		}
+		nodes = append(nodes, g.El("polyline",
+			g.Attr("points", strings.Join(coords, " ")),
+			g.Attr("fill", "none"), g.Attr("stroke", plotDim), g.Attr("stroke-width", "1.5"),
+		))
+	}
+
+	for i, p := range pts {
+		u, v, _ := proj(p)
+		mark := []g.Node{g.Attr("fill", plotColor), plotAttr("r", 3)}
+		if i == len(pts)-1 {
+			mark = []g.Node{g.Attr("fill", plotBg), g.Attr("stroke", plotColor), g.Attr("stroke-width", "2"), plotAttr("r", 5)}
+		}
+		nodes = append(nodes, g.El("circle",
+			plotAttr("cx", x(u)), plotAttr("cy", y(v)), g.Group(mark),
+			g.El("title", g.Textf("#%d %s %s, %s %s", i, hName, lengthText(u, unit), vName, lengthText(v, unit))),
+		))
+	}
+
+	if len(trace) > 0 {
+		u, v, _ := proj(trace[len(trace)-1])
+		cx, cy := x(u), y(v)
+		nodes = append(nodes, g.El("path",
+			g.Attr("d", "M"+plotNum(cx-7)+" "+plotNum(cy)+"h14M"+plotNum(cx)+" "+plotNum(cy-7)+"v14"),
+			g.Attr("stroke", plotColor), g.Attr("stroke-width", "2"),
+			g.El("title", g.Textf("Probe %s %s, %s %s", hName, lengthText(u, unit), vName, lengthText(v, unit))),
+		))
+	}
+	return SVG(g.Group(frame), g.Group(nodes))
+}
+
+func plotNum(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+func plotAttr(name string, v float64) g.Node {
+	return g.Attr(name, plotNum(v))
+}
+
+func plotText(x, y float64, anchor, text string) g.Node {
+	return g.El("text",
+		plotAttr("x", x), plotAttr("y", y), g.Attr("text-anchor", anchor),
+		g.Attr("fill", plotColor), g.Attr("font-size", "11"), g.Attr("font-family", "'Courier New', monospace"),
+		g.Text(text),
+	)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// pointLog appends every captured point to pointLogPath as an ASC line, so
+// a long unattended run survives a browser or server crash.
+var pointLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// openPointLog starts appending points to path, keeping what an earlier run
+// logged. It does nothing when path is "".
+func openPointLog(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("point log: %w", err)
+	}
+	// FreeCAD and /api/points/import skip # lines, so the log stays loadable.
+	if _, err := fmt.Fprintf(f, "# closinuf %s points from %s (mm)\n", version, time.Now().Format(time.RFC3339)); err != nil {
+		f.Close()
+		return fmt.Errorf("point log: %w", err)
+	}
+	pointLog.mu.Lock()
+	pointLog.f = f
+	pointLog.mu.Unlock()
+	slog.Info("logging points", "path", path)
+	return nil
+}
+
+// logPoint appends p's "X Y Z" line, when logging. Each line goes straight
+// to the file, unbuffered.
+func logPoint(p point) {
+	pointLog.mu.Lock()
+	defer pointLog.mu.Unlock()
+	if pointLog.f == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(pointLog.f, "%.6f %.6f %.6f\n", p.x, p.y, p.z); err != nil {
+		slog.Error("point log", "err", err)
+	}
+}
+
+func closePointLog() {
+	pointLog.mu.Lock()
+	defer pointLog.mu.Unlock()
+	if pointLog.f == nil {
+		return
+	}
+	if err := pointLog.f.Close(); err != nil {
+		slog.Error("point log", "err", err)
+	}
+	pointLog.f = nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// session is a named set of captured points, e.g. one part. Points are
+// always stored in mm; unit is the display unit picked when it was created.
+type session struct {
+	name    string
+	created time.Time
+	unit    string
+	points  []point
+	// align is the align-to-edge frame for exports, nil when unaligned. It
+	// is replaced, never modified, so snapshots can share it.
+	align *alignment
+}
+
+// sessionInfo describes a session for /api/sessions.
+type sessionInfo struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+	Unit    string    `json:"unit"`
+	Points  int       `json:"points"`
+	Active  bool      `json:"active"`
+}
+
+const (
+	defaultSessionName = "default"
+	maxSessionName     = 64
+)
+
+var (
+	errSessionExists   = errors.New("session already exists")
+	errSessionNotFound = errors.New("no such session")
+	errSessionActive   = errors.New("cannot delete the active session")
+)
+
+// sessions and active are guarded by pointsMu. Captures, undo, zero, and
+// exports all act on the active session.
+var (
+	sessions = map[string]*session{defaultSessionName: newSession(defaultSessionName, "mm")}
+	active   = sessions[defaultSessionName]
+)
+
+func newSession(name, unit string) *session {
+	return &session{name: name, created: time.Now(), unit: unit}
+}
+
+// validateSessionName trims name and rejects one that is empty, too long,
+// or can't be used as a URL path segment.
+func validateSessionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "":
+		return "", fmt.Errorf("session name is empty")
+	case len(name) > maxSessionName:
+		return "", fmt.Errorf("session name longer than %d bytes", maxSessionName)
+	case strings.ContainsAny(name, "/\\?#"):
+		return "", fmt.Errorf("session name %q contains / \\ ? or #", name)
+	}
+	return name, nil
+}
+
+// createSession adds an empty session and makes it active.
+func createSession(name, unit string) (sessionInfo, error) {
+	name, err := validateSessionName(name)
+	if err != nil {
+		return sessionInfo{}, err
+	}
+	if unit == "" {
+		unit = "mm"
+	}
+	if _, err := mmPerUnit(unit); err != nil {
+		return sessionInfo{}, err
+	}
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	if _, ok := sessions[name]; ok {
+		return sessionInfo{}, fmt.Errorf("%w: %s", errSessionExists, name)
+	}
+	// Fiber's request strings are reused after the handler returns.
+	s := newSession(strings.Clone(name), strings.Clone(unit))
+	sessions[s.name] = s
+	active = s
+	notePointsChanged()
+	return s.info(), nil
+}
+
+// activateSession switches captures and exports to the named session.
+func activateSession(name string) error {
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	s, ok := sessions[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", errSessionNotFound, name)
+	}
+	active = s
+	notePointsChanged()
+	return nil
+}
+
+// deleteSession drops a session and its points. The active one can't be
+// deleted; switch away from it first.
+func deleteSession(name string) error {
+	pointsMu.Lock()
+	defer pointsMu.Unlock()
+	s, ok := sessions[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", errSessionNotFound, name)
+	}
+	if s == active {
+		return errSessionActive
+	}
+	delete(sessions, name)
+	notePointsChanged()
+	return nil
+}
+
+// listSessions returns every session, oldest first.
+func listSessions() []sessionInfo {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	return listSessionsLocked()
+}
+
+// listSessionsLocked is listSessions for callers already holding pointsMu.
+func listSessionsLocked() []sessionInfo {
+	list := make([]sessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, s.info())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Created.Equal(list[j].Created) {
+			return list[i].Created.Before(list[j].Created)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func activeSessionName() string {
+	pointsMu.RLock()
+	defer pointsMu.RUnlock()
+	return active.name
+}
+
+// info must be called with pointsMu held.
+func (s *session) info() sessionInfo {
+	return sessionInfo{
+		Name:    s.name,
+		Created: s.created,
+		Unit:    s.unit,
+		Points:  len(s.points),
+		Active:  s == active,
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// sseKeepAlive is how often an idle SSE stream gets a comment line, so a
+// client that went away is noticed (the write fails) even when nothing moves.
+const sseKeepAlive = 15 * time.Second
+
+// encoderHub fans encoderData JSON out to live clients. A single goroutine
+// (broadcastEncodersForever) produces each message; every subscriber gets a
+// one-slot channel that always holds the latest message, so a slow client
+// skips stale updates instead of holding up the others.
+type encoderHub struct {
+	mu     sync.Mutex
+	subs   map[chan []byte]struct{}
+	last   []byte // most recent message, sent to new subscribers
+	closed bool
+}
+
+var encoderUpdates = &encoderHub{subs: map[chan []byte]struct{}{}}
+
+// subscribe registers a client. The channel is closed when the hub shuts down.
+func (h *encoderHub) subscribe() chan []byte {
+	ch := make(chan []byte, 1)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch
+	}
+	h.subs[ch] = struct{}{}
+	if h.last != nil {
+		ch <- h.last
+	}
+	return ch
+}
+
+func (h *encoderHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[ch]; ok {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+func (h *encoderHub) publish(msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = msg
+	for ch := range h.subs {
+		select {
+		case <-ch: // drop the stale message the client hasn't taken yet
+		default:
+		}
+		ch <- msg
+	}
+}
+
+// close disconnects every subscriber so streaming handlers return on shutdown.
+func (h *encoderHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// broadcastEncodersForever publishes getEncoderData whenever encoderVersion
+// moves, at most once per cfg().pushInterval, until ctx is cancelled. Changes
+// within an interval go out as one message with the latest readings.
+func broadcastEncodersForever(ctx context.Context) {
+	ticker := time.NewTicker(cfg().pushInterval())
+	defer ticker.Stop()
+	var last uint64
+	sent := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		v := encoderVersion.Load()
+		if sent && v == last {
+			continue
+		}
+		last, sent = v, true
+		msg, err := json.Marshal(getEncoderData())
+		if err != nil {
+			slog.Error("encoder push", "err", err)
+			continue
+		}
+		encoderUpdates.publish(msg)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEncoderHubSlowClient(t *testing.T) {
+	h := &encoderHub{subs: map[chan []byte]struct{}{}}
+	stalled := h.subscribe() // never read until the end
+	others := []chan []byte{h.subscribe(), h.subscribe()}
+
+	const n = 100
+	done := make(chan struct{})
+	go func() {
+		for i := range n {
+			h.publish([]byte(fmt.Sprint(i)))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish blocked on a client that isn't reading")
+	}
+
+	want := fmt.Sprint(n - 1)
+	for i, ch := range append(others, stalled) {
+		select {
+		case msg := <-ch:
+			if string(msg) != want {
+				t.Errorf("client %d got %q, want the latest %q", i, msg, want)
+			}
+		default:
+			t.Errorf("client %d has no message", i)
+		}
+		select {
+		case msg := <-ch:
+			t.Errorf("client %d has a second message %q queued", i, msg)
+		default:
+		}
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"math"
+	"slices"
+	"strconv"
 	"strings"
 
 	g "maragu.dev/gomponents"
@@ -12,299 +14,143 @@ import (
 
 const appTitle = "closinuf"
 
-func page(data encoderData, unit string) g.Node {
+// Themes: the neon-on-black CRT look, and a high-contrast light one for
+// bright shops. Only colours change.
+const (
+	themeDark  = "dark"
+	themeLight = "light"
+)
+
+// validTheme maps anything unknown (or empty) to the dark theme.
+func validTheme(theme string) string {
+	if theme == themeLight {
+		return themeLight
+	}
+	return themeDark
+}
+
+// refreshChoices are the readout refresh intervals offered, in ms. Point
+// readouts (count, distance, extents, plot) refresh 5× less often.
+var refreshChoices = []int{100, 200, 500, 1000, 2000}
+
+const defaultRefreshMs = 200
+
+// validRefresh maps anything not in refreshChoices to the default.
+func validRefresh(ms int) int {
+	if slices.Contains(refreshChoices, ms) {
+		return ms
+	}
+	return defaultRefreshMs
+}
+
+// liveTrigger refreshes a readout on each WebSocket push, at most once per
+// refresh ms, polling at that rate only while the socket is down (see
+// liveUpdates).
+func liveTrigger(refresh int) string {
+	return fmt.Sprintf("every %dms [!window.encoderSocketOpen], encoder-changed from:body throttle:%dms", refresh, refresh)
+}
+
+// pointsTrigger polls a point readout.
+func pointsTrigger(refresh int) string {
+	return fmt.Sprintf("every %dms", 5*refresh)
+}
+
+// pageVals sends the display unit, angle unit, and refresh interval with
+// fragment requests: ?unit= when the page has one, else the values the page
+// was rendered with (from the cookies).
+const pageVals = "js:{unit: new URLSearchParams(window.location.search).get('unit') || document.body.dataset.unit || 'mm', angle: document.body.dataset.angle, refresh: document.body.dataset.refresh}"
+
+func page(data encoderData, unit, angle, theme string, refresh int) g.Node {
 	return HTML(
-		Head(
-			Meta(Charset("utf-8")),
-			Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
-			TitleEl(g.Text(appTitle)),
-			Script(Src("https://unpkg.com/htmx.org@2.0.3/dist/htmx.min.js")),
-			StyleEl(g.Raw(`
-				@import url('https://fonts.googleapis.com/css2?family=Orbitron:wght@400;700;900&display=swap');
-				* {
-					box-sizing: border-box;
-				}
-				html {
-					scroll-padding-bottom: clamp(10rem, 48vh, 440px);
-				}
-				body {
-					font-family: 'Courier New', 'Courier', monospace;
-					min-height: 100vh;
-					display: flex;
-					flex-direction: column;
-					justify-content: center;
-					align-items: center;
-					margin: 0;
-					padding: 2rem;
-					padding-bottom: clamp(10rem, 48vh, 440px);
-					background: #0a0a0a;
-					color: #00ff41;
-					text-shadow: 0 0 2px #00ff41, 0 0 4px rgba(0, 255, 65, 0.35);
-				}
-				.container {
-					position: relative;
-					max-width: 1000px;
-					width: 100%;
-					background: #0d0d0d;
-					border-radius: 8px;
-					padding: 1.5rem;
-					border: 2px solid #00ff41;
-					box-shadow: 0 0 20px rgba(0, 255, 65, 0.3), inset 0 0 20px rgba(0, 255, 65, 0.05);
-				}
-				h1 {
-					margin-top: 0;
-					color: #00ff41;
-					text-shadow: 0 0 2px #00ff41, 0 0 6px rgba(0, 255, 65, 0.4);
-					font-family: 'Orbitron', monospace;
-					font-weight: 700;
-				}
-				.encoder-display {
-					display: flex;
-					gap: 1rem;
-					margin-bottom: 1rem;
-					flex-wrap: wrap;
-					justify-content: center;
-				}
-				.encoder-card {
-					background: #0a0a0a;
-					border-radius: 6px;
-					padding: 1rem;
-					border: 1px solid #00ff41;
-					box-shadow: 0 0 10px rgba(0, 255, 65, 0.2), inset 0 0 10px rgba(0, 255, 65, 0.05);
-					min-width: 200px;
-					flex: 1;
-					text-align: center;
-				}
-				.encoder-label {
-					font-weight: bold;
-					color: #00ff41;
-					font-size: 1.2rem;
-					margin-bottom: 0.5rem;
-					text-shadow: 0 0 2px #00ff41, 0 0 5px rgba(0, 255, 65, 0.35);
-					font-family: 'Orbitron', monospace;
-					font-weight: 700;
-				}
-				.encoder-distance {
-					font-size: 2rem;
-					font-weight: 700;
-					color: #00ff41;
-					line-height: 1.2;
-					margin-bottom: 0.5rem;
-					font-variant-numeric: tabular-nums;
-					text-shadow: 0 0 2px #00ff41, 0 0 6px rgba(0, 255, 65, 0.4);
-					font-family: 'Courier New', monospace;
-				}
-				.encoder-delta {
-					font-size: 2rem;
-					font-weight: 700;
-					line-height: 1.2;
-					margin-bottom: 0.5rem;
-					font-variant-numeric: tabular-nums;
-					font-family: 'Courier New', monospace;
-				}
-				.encoder-delta-zero {
-					color: #00ff41;
-					text-shadow: 0 0 2px #00ff41, 0 0 5px rgba(0, 255, 65, 0.35);
-				}
-				.encoder-delta-nonzero {
-					color: #ff4444;
-					text-shadow: 0 0 2px #ff4444, 0 0 5px rgba(255, 68, 68, 0.45);
-				}
-				.encoder-delta-nonzero .encoder-unit-large {
-					color: #ff4444;
-					text-shadow: 0 0 2px #ff4444, 0 0 5px rgba(255, 68, 68, 0.45);
-				}
-				.encoder-delta .encoder-unit-large {
-					font-size: 1.5rem;
-					margin-left: 0.25rem;
-				}
-				.encoder-unit-large {
-					font-size: 1.5rem;
-					color: #00ff41;
-					margin-left: 0.25rem;
-					font-weight: 400;
-					text-shadow: 0 0 2px #00ff41;
-				}
-				.encoder-details {
-					display: flex;
-					flex-direction: column;
-					gap: 0.25rem;
-					font-size: 0.85rem;
-					color: #00cc33;
-					text-shadow: 0 0 1px #00cc33;
-				}
-				.encoder-detail-item {
-					font-variant-numeric: tabular-nums;
-				}
-				.encoder-unit-small {
-					color: #00cc33;
-					margin-left: 0.15rem;
-					text-shadow: 0 0 1px #00cc33;
-				}
-				.encoder-other-units {
-					font-size: 0.75rem;
-					color: #009922;
-					margin-top: 0.25rem;
-					text-shadow: 0 0 1px #009922;
-				}
-				.units-button, .zero-button, .point-button, .save-button {
-					background: #0a0a0a;
-					color: #00ff41;
-					border: 2px solid #00ff41;
-					padding: 0.75rem 1.5rem;
-					border-radius: 4px;
-					font-size: 1rem;
-					font-weight: 600;
-					cursor: pointer;
-					transition: all 0.15s ease;
-					font-family: 'Courier New', monospace;
-					text-shadow: 0 0 2px #00ff41;
-					box-shadow: 0 0 10px rgba(0, 255, 65, 0.3);
-					position: relative;
-					-webkit-tap-highlight-color: transparent;
-				}
-				.units-button:hover, .zero-button:hover, .point-button:hover, .save-button:hover {
-					background: rgba(0, 255, 65, 0.1);
-					box-shadow: 0 0 15px rgba(0, 255, 65, 0.5);
-					text-shadow: 0 0 2px #00ff41, 0 0 5px rgba(0, 255, 65, 0.35);
-				}
-				.units-button:active, .zero-button:active, .point-button:active, .save-button:active {
-					background: rgba(0, 255, 65, 0.25);
-					box-shadow: 0 0 25px rgba(0, 255, 65, 0.8), 0 0 40px rgba(0, 255, 65, 0.4);
-					text-shadow: 0 0 3px #00ff41, 0 0 7px rgba(0, 255, 65, 0.4);
-					transform: scale(0.98);
-					border-color: #00ff88;
-				}
-				.point-button {
-					background: rgba(0, 255, 65, 0.15);
-					border-color: #00ff41;
-					box-shadow: 0 0 15px rgba(0, 255, 65, 0.4);
-				}
-				.point-button:hover {
-					background: rgba(0, 255, 65, 0.25);
-					box-shadow: 0 0 20px rgba(0, 255, 65, 0.6);
-				}
-				.point-button:active {
-					background: rgba(0, 255, 65, 0.35);
-					box-shadow: 0 0 30px rgba(0, 255, 65, 0.9), 0 0 50px rgba(0, 255, 65, 0.5);
-				}
-				.save-button {
-					background: rgba(255, 200, 0, 0.1);
-					border-color: #ffc800;
-					color: #ffc800;
-					text-shadow: 0 0 2px #ffc800;
-					box-shadow: 0 0 10px rgba(255, 200, 0, 0.3);
-				}
-				.save-button:hover {
-					background: rgba(255, 200, 0, 0.2);
-					box-shadow: 0 0 15px rgba(255, 200, 0, 0.5);
-					text-shadow: 0 0 2px #ffc800, 0 0 5px rgba(255, 200, 0, 0.45);
-				}
-				.save-button:active {
-					background: rgba(255, 200, 0, 0.3);
-					box-shadow: 0 0 25px rgba(255, 200, 0, 0.8), 0 0 40px rgba(255, 200, 0, 0.4);
-					text-shadow: 0 0 3px #ffc800, 0 0 7px rgba(255, 200, 0, 0.45);
-					border-color: #ffd700;
-				}
-				.button-container {
-					text-align: center;
-					margin-top: 2rem;
-					display: flex;
-					gap: 1rem;
-					justify-content: center;
-					align-items: center;
-					flex-wrap: wrap;
-				}
-				.points-count {
-					font-size: 1rem;
-					color: #00ff41;
-					font-weight: 500;
-					padding: 0.75rem 1rem;
-					background: #0a0a0a;
-					border-radius: 6px;
-					border: 1px solid #00ff41;
-					box-shadow: 0 0 8px rgba(0, 255, 65, 0.2);
-					text-shadow: 0 0 2px #00ff41;
-				}
-				.filename-input {
-					padding: 0.75rem 1rem;
-					border: 2px solid #00ff41;
-					border-radius: 6px;
-					font-size: 1rem;
-					width: 150px;
-					background: #0a0a0a;
-					color: #00ff41;
-					font-family: 'Courier New', monospace;
-					text-shadow: 0 0 2px #00ff41;
-					transition: all 0.2s;
-					box-shadow: 0 0 8px rgba(0, 255, 65, 0.2);
-				}
-				.filename-input:focus {
-					outline: none;
-					border-color: #00ff41;
-					box-shadow: 0 0 15px rgba(0, 255, 65, 0.5);
-					text-shadow: 0 0 3px #00ff41;
-				}
-				.filename-input::placeholder {
-					color: #009922;
-					text-shadow: 0 0 1px #009922;
-				}
-				.save-group {
-					display: flex;
-					gap: 0.5rem;
-					align-items: center;
-				}
-				.save-error {
-					position: absolute;
-					top: 50%;
-					left: 50%;
-					transform: translate(-50%, -50%);
-					color: #ff0000;
-					background: rgba(0, 0, 0, 0.95);
-					border: 3px solid #ff0000;
-					padding: 0.75rem 1rem;
-					border-radius: 6px;
-					text-align: center;
-					white-space: nowrap;
-					z-index: 1000;
-					box-shadow: 0 0 20px rgba(255, 0, 0, 0.8), inset 0 0 10px rgba(255, 0, 0, 0.2);
-					animation: fadeOut 0.5s ease-out 5s forwards;
-					text-shadow: 0 0 2px #ff0000, 0 0 5px rgba(255, 0, 0, 0.45);
-					font-weight: bold;
-				}
-				@keyframes fadeOut {
-					from {
-						opacity: 1;
-					}
-					to {
-						opacity: 0;
-						visibility: hidden;
-					}
-				}
-			`)),
-		),
+		pageHead(appTitle),
 		Body(
+			Class("theme-"+theme),
+			Data("unit", unit),
+			Data("angle", angle),
+			Data("theme", theme),
+			Data("refresh", strconv.Itoa(refresh)),
 			Div(Class("container"),
 				H1(g.Text(appTitle)),
-				encoderFragment(data, unit),
+				encoderFragment(data, unit, angle, refresh),
 				Div(Class("button-container"),
+					Input(
+						ID("point-label"),
+						Name("label"),
+						Type("text"),
+						Class("filename-input"),
+						MaxLength(strconv.Itoa(maxPointLabel)),
+						Placeholder("label (optional)"),
+						Title("Label for the next captured point"),
+					),
 					Button(
+						ID("capture-button"),
 						Class("point-button"),
+						Title("Capture Point (Space or Enter)"),
 						hx.Post("/api/points/add"),
+						hx.Include("#point-label"),
 						hx.Trigger("click"),
 						hx.Swap("none"),
 						hx.Target("#points-count"),
-						hx.On("htmx:afterRequest", "htmx.trigger('#points-count', 'htmx:trigger')"),
+						hx.On("htmx:afterRequest", "htmx.trigger('#points-count', 'htmx:trigger'); if (event.detail.successful) document.getElementById('point-label').value = ''"),
+						hx.On("htmx:responseError", "showCaptureError(event.detail.xhr)"),
 						g.Text("Capture Point"),
 					),
+					Button(
+						ID("undo-button"),
+						Class("undo-button"),
+						Title("Undo (U)"),
+						hx.Post("/api/points/undo"),
+						hx.Trigger("click"),
+						hx.Target("#points-count"),
+						hx.Swap("innerHTML"),
+						g.Text("Undo"),
+					),
+					Button(
+						ID("clear-points-button"),
+						Class("undo-button"),
+						Title("Discard all captured points; the axes keep their zero"),
+						hx.Post("/api/points/clear"),
+						hx.Confirm("Discard all captured points?"),
+						hx.Trigger("click"),
+						hx.Target("#points-count"),
+						hx.Swap("innerHTML"),
+						g.Text("Clear Points"),
+					),
 					Span(
 						ID("points-count"),
 						Class("points-count"),
 						hx.Get("/api/points/count"),
-						hx.Trigger("every 1s"),
+						hx.Trigger(pointsTrigger(refresh)),
 						hx.Swap("innerHTML"),
 						g.Text("Points: 0"),
 					),
+					Span(
+						ID("points-distance"),
+						Class("points-count"),
+						hx.Get("/api/points/distance/htmx"),
+						hx.Trigger(pointsTrigger(refresh)),
+						hx.Vals(pageVals),
+						hx.Swap("innerHTML"),
+						g.Text("Distance: –"),
+					),
+					Span(
+						ID("points-angle"),
+						Class("points-count"),
+						hx.Get("/api/points/angle/htmx"),
+						hx.Trigger(pointsTrigger(refresh)),
+						hx.Swap("innerHTML"),
+						g.Text("Angle: –"),
+					),
+					Span(
+						ID("points-extents"),
+						Class("points-count"),
+						hx.Get("/api/points/bounds/htmx"),
+						hx.Trigger(pointsTrigger(refresh)),
+						hx.Vals(pageVals),
+						hx.Swap("innerHTML"),
+						g.Text("Extents: –"),
+					),
+					sessionPicker(listSessions()),
 					Div(
 						Class("save-group"),
 						Input(
@@ -329,19 +175,63 @@ func page(data encoderData, unit string) g.Node {
 						ID("save-error"),
 						g.Attr("style", "display: none;"),
 					),
+					Div(
+						ID("capture-error"),
+						Class("save-error"),
+						g.Attr("style", "display: none;"),
+					),
 					Div(
 						g.Attr("style", "width: 100%; flex-basis: 100%;"),
 					),
 					Button(
+						ID("units-button"),
 						Class("units-button"),
+						Title("Units (C)"),
 						hx.Get("/api/units/cycle"),
-						hx.Vals("js:{unit: new URLSearchParams(window.location.search).get('unit') || 'mm'}"),
+						hx.Vals(pageVals),
 						hx.Trigger("click"),
 						hx.Swap("none"),
 						g.Text("Units"),
 					),
+					g.If(hasRotary(data), Button(
+						Class("units-button"),
+						Title("Angle unit for rotary axes"),
+						hx.Get("/api/units/angle/cycle"),
+						hx.Vals(pageVals),
+						hx.Trigger("click"),
+						hx.Swap("none"),
+						g.Text("Angle"),
+					)),
+					Button(
+						ID("mode-button"),
+						Class("units-button"),
+						hx.Post("/api/encoder/mode"),
+						hx.Trigger("click"),
+						hx.Swap("innerHTML"),
+						g.Text(strings.ToUpper(data.Mode)),
+					),
 					Button(
+						ID("hold-button"),
+						Class("units-button"),
+						Title("Freeze the readings (H)"),
+						hx.Post("/api/encoder/hold"),
+						hx.Trigger("click"),
+						hx.Swap("innerHTML"),
+						g.Text(holdLabel(data.Hold)),
+					),
+					Button(
+						Class("units-button"),
+						Title("Switch between the dark and light themes"),
+						hx.Post("/api/theme/toggle"),
+						hx.Vals("js:{theme: document.body.dataset.theme}"),
+						hx.Swap("none"),
+						g.Text("Theme"),
+					),
+					refreshPicker(refresh),
+					Button(
+						ID("zero-button"),
 						Class("zero-button"),
+						Title("Zero All Counts and clear points (Z)"),
 						hx.Post("/api/encoder/zero"),
 						hx.Trigger("click"),
 						hx.Swap("none"),
@@ -349,27 +239,636 @@ func page(data encoderData, unit string) g.Node {
 						g.Text("Zero All Counts"),
 					),
 				),
+				Div(Class("plot-group"),
+					Select(
+						ID("plot-plane"),
+						Name("plane"),
+						Class("session-select"),
+						Option(Value("xy"), g.Text("XY")),
+						Option(Value("xz"), g.Text("XZ")),
+						Option(Value("yz"), g.Text("YZ")),
+					),
+					Div(
+						ID("points-plot"),
+						hx.Get("/api/points/plot"),
+						hx.Trigger("load, "+pointsTrigger(refresh)+", change from:#plot-plane"),
+						hx.Include("#plot-plane"),
+						hx.Vals(pageVals),
+						hx.Swap("innerHTML"),
+					),
+				),
 			),
+			liveUpdates(),
+			Script(g.Raw(`
+				// A rejected capture (too close to the last point) explains
+				// itself for a few seconds.
+				function showCaptureError(xhr) {
+					if (xhr.status !== 409) return;
+					const el = document.getElementById('capture-error');
+					el.textContent = xhr.responseText;
+					el.style.display = '';
+					clearTimeout(el.hideTimer);
+					el.hideTimer = setTimeout(() => { el.style.display = 'none'; }, 3000);
+				}
+				// Keyboard shortcuts: Space or Enter captures a point, U undoes,
+				// Z zeroes all counts, C cycles units, H toggles hold. They are
+				// off while typing in a text box or choosing from a dropdown,
+				// except that Enter in the label box captures.
+				document.addEventListener('keydown', (e) => {
+					if (e.ctrlKey || e.metaKey || e.altKey || e.repeat) return;
+					if (e.key === 'Enter' && e.target.id === 'point-label') {
+						e.preventDefault();
+						document.getElementById('capture-button').click();
+						return;
+					}
+					if (e.target.closest('input, select, textarea')) return;
+					const capture = e.key === ' ' || e.key === 'Enter';
+					// A focused button already handles Space and Enter itself.
+					if (capture && e.target.closest('button')) return;
+					const id = capture ? 'capture-button' : {u: 'undo-button', z: 'zero-button', c: 'units-button', h: 'hold-button'}[e.key.toLowerCase()];
+					if (!id) return;
+					e.preventDefault();
+					document.getElementById(id).click();
+				});
+			`)),
 		),
 	)
 }
 
-func encoderFragment(data encoderData, unit string) g.Node {
+// pageHead is the <head> shared by the dashboard and the DRO view: htmx and
+// all of the page CSS.
+func pageHead(title string) g.Node {
+	return Head(
+		Meta(Charset("utf-8")),
+		Meta(Name("viewport"), Content("width=device-width, initial-scale=1")),
+		TitleEl(g.Text(title)),
+		Script(Src("https://unpkg.com/htmx.org@2.0.3/dist/htmx.min.js")),
+		StyleEl(g.Raw(`
+			@import url('https://fonts.googleapis.com/css2?family=Orbitron:wght@400;700;900&display=swap');
+			* {
+				box-sizing: border-box;
+			}
+			html {
+				scroll-padding-bottom: clamp(10rem, 48vh, 440px);
+			}
+			body {
+				font-family: 'Courier New', 'Courier', monospace;
+				min-height: 100vh;
+				display: flex;
+				flex-direction: column;
+				justify-content: center;
+				align-items: center;
+				margin: 0;
+				padding: 2rem;
+				padding-bottom: clamp(10rem, 48vh, 440px);
+				background: #0a0a0a;
+				color: #00ff41;
+				text-shadow: 0 0 2px #00ff41, 0 0 4px rgba(0, 255, 65, 0.35);
+			}
+			.container {
+				position: relative;
+				max-width: 1000px;
+				width: 100%;
+				background: #0d0d0d;
+				border-radius: 8px;
+				padding: 1.5rem;
+				border: 2px solid #00ff41;
+				box-shadow: 0 0 20px rgba(0, 255, 65, 0.3), inset 0 0 20px rgba(0, 255, 65, 0.05);
+			}
+			h1 {
+				margin-top: 0;
+				color: #00ff41;
+				text-shadow: 0 0 2px #00ff41, 0 0 6px rgba(0, 255, 65, 0.4);
+				font-family: 'Orbitron', monospace;
+				font-weight: 700;
+			}
+			.encoder-display {
+				display: flex;
+				gap: 1rem;
+				margin-bottom: 1rem;
+				flex-wrap: wrap;
+				justify-content: center;
+			}
+			.encoder-card {
+				background: #0a0a0a;
+				border-radius: 6px;
+				padding: 1rem;
+				border: 1px solid #00ff41;
+				box-shadow: 0 0 10px rgba(0, 255, 65, 0.2), inset 0 0 10px rgba(0, 255, 65, 0.05);
+				min-width: 200px;
+				flex: 1;
+				text-align: center;
+			}
+			.encoder-label {
+				font-weight: bold;
+				color: #00ff41;
+				font-size: 1.2rem;
+				margin-bottom: 0.5rem;
+				text-shadow: 0 0 2px #00ff41, 0 0 5px rgba(0, 255, 65, 0.35);
+				font-family: 'Orbitron', monospace;
+				font-weight: 700;
+			}
+			.encoder-distance {
+				font-size: 2rem;
+				font-weight: 700;
+				color: #00ff41;
+				line-height: 1.2;
+				margin-bottom: 0.5rem;
+				font-variant-numeric: tabular-nums;
+				text-shadow: 0 0 2px #00ff41, 0 0 6px rgba(0, 255, 65, 0.4);
+				font-family: 'Courier New', monospace;
+			}
+			.encoder-clamped,
+			.encoder-clamped .encoder-unit-large {
+				color: #ff4444;
+				text-shadow: 0 0 2px #ff4444, 0 0 5px rgba(255, 68, 68, 0.45);
+			}
+			.encoder-diameter {
+				color: #ffc800;
+				text-shadow: 0 0 2px #ffc800;
+			}
+			.hold-badge {
+				text-align: center;
+				font-weight: 900;
+				letter-spacing: 0.3em;
+				color: #ffc800;
+				text-shadow: 0 0 2px #ffc800, 0 0 6px rgba(255, 200, 0, 0.5);
+				margin-bottom: 0.5rem;
+			}
+			.encoder-direction {
+				font-size: 0.8em;
+			}
+			.encoder-errors {
+				color: #ff4444;
+			}
+			.encoder-delta {
+				font-size: 2rem;
+				font-weight: 700;
+				line-height: 1.2;
+				margin-bottom: 0.5rem;
+				font-variant-numeric: tabular-nums;
+				font-family: 'Courier New', monospace;
+			}
+			.encoder-delta-zero {
+				color: #00ff41;
+				text-shadow: 0 0 2px #00ff41, 0 0 5px rgba(0, 255, 65, 0.35);
+			}
+			.encoder-delta-nonzero {
+				color: #ff4444;
+				text-shadow: 0 0 2px #ff4444, 0 0 5px rgba(255, 68, 68, 0.45);
+			}
+			.encoder-delta-nonzero .encoder-unit-large {
+				color: #ff4444;
+				text-shadow: 0 0 2px #ff4444, 0 0 5px rgba(255, 68, 68, 0.45);
+			}
+			.encoder-delta .encoder-unit-large {
+				font-size: 1.5rem;
+				margin-left: 0.25rem;
+			}
+			.encoder-unit-large {
+				font-size: 1.5rem;
+				color: #00ff41;
+				margin-left: 0.25rem;
+				font-weight: 400;
+				text-shadow: 0 0 2px #00ff41;
+			}
+			body.dro {
+				padding: 1rem;
+			}
+			.dro #dro {
+				width: 100%;
+			}
+			.dro .encoder-card {
+				min-height: calc(100vh - 2rem);
+				display: flex;
+				flex-direction: column;
+				justify-content: center;
+			}
+			.dro .encoder-label {
+				font-size: min(8vw, 4rem);
+			}
+			.dro .encoder-distance {
+				font-size: min(14vw, 40vh);
+				white-space: nowrap;
+			}
+			.dro .encoder-unit-large {
+				font-size: 0.3em;
+			}
+			.dro .encoder-details {
+				font-size: 1.5rem;
+			}
+			.encoder-details {
+				display: flex;
+				flex-direction: column;
+				gap: 0.25rem;
+				font-size: 0.85rem;
+				color: #00cc33;
+				text-shadow: 0 0 1px #00cc33;
+			}
+			.encoder-detail-item {
+				font-variant-numeric: tabular-nums;
+			}
+			.encoder-unit-small {
+				color: #00cc33;
+				margin-left: 0.15rem;
+				text-shadow: 0 0 1px #00cc33;
+			}
+			.encoder-other-units {
+				font-size: 0.75rem;
+				color: #009922;
+				margin-top: 0.25rem;
+				text-shadow: 0 0 1px #009922;
+			}
+			.units-button, .zero-button, .point-button, .save-button, .undo-button, .session-button {
+				background: #0a0a0a;
+				color: #00ff41;
+				border: 2px solid #00ff41;
+				padding: 0.75rem 1.5rem;
+				border-radius: 4px;
+				font-size: 1rem;
+				font-weight: 600;
+				cursor: pointer;
+				transition: all 0.15s ease;
+				font-family: 'Courier New', monospace;
+				text-shadow: 0 0 2px #00ff41;
+				box-shadow: 0 0 10px rgba(0, 255, 65, 0.3);
+				position: relative;
+				-webkit-tap-highlight-color: transparent;
+			}
+			.units-button:hover, .zero-button:hover, .point-button:hover, .save-button:hover, .undo-button:hover, .session-button:hover {
+				background: rgba(0, 255, 65, 0.1);
+				box-shadow: 0 0 15px rgba(0, 255, 65, 0.5);
+				text-shadow: 0 0 2px #00ff41, 0 0 5px rgba(0, 255, 65, 0.35);
+			}
+			.units-button:active, .zero-button:active, .point-button:active, .save-button:active, .undo-button:active, .session-button:active {
+				background: rgba(0, 255, 65, 0.25);
+				box-shadow: 0 0 25px rgba(0, 255, 65, 0.8), 0 0 40px rgba(0, 255, 65, 0.4);
+				text-shadow: 0 0 3px #00ff41, 0 0 7px rgba(0, 255, 65, 0.4);
+				transform: scale(0.98);
+				border-color: #00ff88;
+			}
+			.point-button {
+				background: rgba(0, 255, 65, 0.15);
+				border-color: #00ff41;
+				box-shadow: 0 0 15px rgba(0, 255, 65, 0.4);
+			}
+			.point-button:hover {
+				background: rgba(0, 255, 65, 0.25);
+				box-shadow: 0 0 20px rgba(0, 255, 65, 0.6);
+			}
+			.point-button:active {
+				background: rgba(0, 255, 65, 0.35);
+				box-shadow: 0 0 30px rgba(0, 255, 65, 0.9), 0 0 50px rgba(0, 255, 65, 0.5);
+			}
+			.save-button {
+				background: rgba(255, 200, 0, 0.1);
+				border-color: #ffc800;
+				color: #ffc800;
+				text-shadow: 0 0 2px #ffc800;
+				box-shadow: 0 0 10px rgba(255, 200, 0, 0.3);
+			}
+			.save-button:hover {
+				background: rgba(255, 200, 0, 0.2);
+				box-shadow: 0 0 15px rgba(255, 200, 0, 0.5);
+				text-shadow: 0 0 2px #ffc800, 0 0 5px rgba(255, 200, 0, 0.45);
+			}
+			.save-button:active {
+				background: rgba(255, 200, 0, 0.3);
+				box-shadow: 0 0 25px rgba(255, 200, 0, 0.8), 0 0 40px rgba(255, 200, 0, 0.4);
+				text-shadow: 0 0 3px #ffc800, 0 0 7px rgba(255, 200, 0, 0.45);
+				border-color: #ffd700;
+			}
+			.button-container {
+				text-align: center;
+				margin-top: 2rem;
+				display: flex;
+				gap: 1rem;
+				justify-content: center;
+				align-items: center;
+				flex-wrap: wrap;
+			}
+			.points-count {
+				font-size: 1rem;
+				color: #00ff41;
+				font-weight: 500;
+				padding: 0.75rem 1rem;
+				background: #0a0a0a;
+				border-radius: 6px;
+				border: 1px solid #00ff41;
+				box-shadow: 0 0 8px rgba(0, 255, 65, 0.2);
+				text-shadow: 0 0 2px #00ff41;
+			}
+			.filename-input {
+				padding: 0.75rem 1rem;
+				border: 2px solid #00ff41;
+				border-radius: 6px;
+				font-size: 1rem;
+				width: 150px;
+				background: #0a0a0a;
+				color: #00ff41;
+				font-family: 'Courier New', monospace;
+				text-shadow: 0 0 2px #00ff41;
+				transition: all 0.2s;
+				box-shadow: 0 0 8px rgba(0, 255, 65, 0.2);
+			}
+			.filename-input:focus {
+				outline: none;
+				border-color: #00ff41;
+				box-shadow: 0 0 15px rgba(0, 255, 65, 0.5);
+				text-shadow: 0 0 3px #00ff41;
+			}
+			.filename-input::placeholder {
+				color: #009922;
+				text-shadow: 0 0 1px #009922;
+			}
+			.session-select {
+				padding: 0.75rem 1rem;
+				border: 2px solid #00ff41;
+				border-radius: 6px;
+				font-size: 1rem;
+				background: #0a0a0a;
+				color: #00ff41;
+				font-family: 'Courier New', monospace;
+				text-shadow: 0 0 2px #00ff41;
+				box-shadow: 0 0 8px rgba(0, 255, 65, 0.2);
+			}
+			.plot-group {
+				display: flex;
+				flex-direction: column;
+				align-items: flex-start;
+				gap: 0.5rem;
+				margin-top: 1rem;
+			}
+			#points-plot {
+				width: 100%;
+			}
+			.points-plot {
+				width: 100%;
+				max-height: 50vh;
+				background: #0a0a0a;
+				border: 1px solid #00ff41;
+				border-radius: 6px;
+			}
+			.save-group, .session-group {
+				display: flex;
+				gap: 0.5rem;
+				align-items: center;
+			}
+			.save-error {
+				position: absolute;
+				top: 50%;
+				left: 50%;
+				transform: translate(-50%, -50%);
+				color: #ff0000;
+				background: rgba(0, 0, 0, 0.95);
+				border: 3px solid #ff0000;
+				padding: 0.75rem 1rem;
+				border-radius: 6px;
+				text-align: center;
+				white-space: nowrap;
+				z-index: 1000;
+				box-shadow: 0 0 20px rgba(255, 0, 0, 0.8), inset 0 0 10px rgba(255, 0, 0, 0.2);
+				animation: fadeOut 0.5s ease-out 5s forwards;
+				text-shadow: 0 0 2px #ff0000, 0 0 5px rgba(255, 0, 0, 0.45);
+				font-weight: bold;
+			}
+			/* High-contrast light theme: same layout, dark ink on white, no glow. */
+			body.theme-light, .theme-light * {
+				text-shadow: none !important;
+				box-shadow: none !important;
+			}
+			body.theme-light {
+				background: #ffffff;
+				color: #000000;
+			}
+			.theme-light .container, .theme-light .encoder-card, .theme-light .points-count,
+			.theme-light .filename-input, .theme-light .session-select, .theme-light .points-plot {
+				background: #ffffff;
+				color: #000000;
+				border-color: #000000;
+			}
+			.theme-light h1, .theme-light .encoder-label, .theme-light .encoder-distance,
+			.theme-light .encoder-unit-large, .theme-light .encoder-delta-zero {
+				color: #000000;
+			}
+			.theme-light .encoder-details, .theme-light .encoder-unit-small {
+				color: #222222;
+			}
+			.theme-light .encoder-other-units, .theme-light .filename-input::placeholder {
+				color: #444444;
+			}
+			.theme-light .encoder-clamped, .theme-light .encoder-clamped .encoder-unit-large,
+			.theme-light .encoder-errors, .theme-light .encoder-delta-nonzero,
+			.theme-light .encoder-delta-nonzero .encoder-unit-large {
+				color: #b00000;
+			}
+			.theme-light .encoder-diameter, .theme-light .hold-badge {
+				color: #8a5a00;
+			}
+			.theme-light .units-button, .theme-light .zero-button, .theme-light .undo-button,
+			.theme-light .session-button {
+				background: #ffffff;
+				color: #000000;
+				border-color: #000000;
+			}
+			.theme-light .point-button {
+				background: #000000;
+				color: #ffffff;
+				border-color: #000000;
+			}
+			.theme-light .save-button {
+				background: #ffffff;
+				color: #8a5a00;
+				border-color: #8a5a00;
+			}
+			.theme-light .units-button:hover, .theme-light .zero-button:hover, .theme-light .undo-button:hover,
+			.theme-light .session-button:hover, .theme-light .save-button:hover {
+				background: #e6e6e6;
+			}
+			.theme-light .point-button:hover {
+				background: #333333;
+			}
+			.theme-light .units-button:active, .theme-light .zero-button:active, .theme-light .undo-button:active,
+			.theme-light .session-button:active, .theme-light .save-button:active {
+				background: #cccccc;
+			}
+			.theme-light .save-error {
+				background: #ffffff;
+				color: #b00000;
+				border-color: #b00000;
+			}
+			/* CSS beats the plot's colour attributes. */
+			.theme-light .points-plot text {
+				fill: #000000;
+			}
+			.theme-light .points-plot circle, .theme-light .points-plot path {
+				fill: #000000;
+				stroke: #000000;
+			}
+			.theme-light .points-plot circle[stroke] {
+				fill: #ffffff;
+			}
+			.theme-light .points-plot rect, .theme-light .points-plot polyline {
+				stroke: #888888;
+			}
+			@keyframes fadeOut {
+				from {
+					opacity: 1;
+				}
+				to {
+					opacity: 0;
+					visibility: hidden;
+				}
+			}
+		`)),
+	)
+}
+
+// liveUpdates refreshes readouts on each /ws/encoder push.
+func liveUpdates() g.Node {
+	return Script(g.Raw(`
+		// Prefer pushed updates over polling: each /ws/encoder message
+		// means a count changed, so refresh the readout. While the
+		// socket is down the readout falls back to polling.
+		(function connect() {
+			const proto = location.protocol === 'https:' ? 'wss:' : 'ws:';
+			const ws = new WebSocket(proto + '//' + location.host + '/ws/encoder');
+			ws.onopen = () => { window.encoderSocketOpen = true; };
+			ws.onmessage = () => htmx.trigger(document.body, 'encoder-changed');
+			ws.onclose = () => {
+				window.encoderSocketOpen = false;
+				setTimeout(connect, 2000);
+			};
+		})();
+	`))
+}
+
+// droPage is the fullscreen single-axis readout at /dro/{axis}: the axis's
+// card alone, scaled up to read across the room.
+func droPage(axis, label string, v encoderValues, unit, angle, theme string, refresh int) g.Node {
+	return HTML(
+		pageHead(label+" – "+appTitle),
+		Body(
+			Class("dro theme-"+theme),
+			Data("unit", unit),
+			Data("angle", angle),
+			Data("refresh", strconv.Itoa(refresh)),
+			droFragment(axis, label, v, unit, angle, refresh),
+			liveUpdates(),
+		),
+	)
+}
+
+func droFragment(axis, label string, v encoderValues, unit, angle string, refresh int) g.Node {
+	return Div(
+		hx.Get("/dro/"+axis+"/htmx"),
+		hx.Trigger(liveTrigger(refresh)),
+		hx.Vals(pageVals),
+		hx.Swap("outerHTML"),
+		hx.Target("this"),
+		ID("dro"),
+		g.If(holdMode.Load(), Div(Class("hold-badge"), g.Text("HOLD"))),
+		axisDisplay(label, v, unit, angle),
+	)
+}
+
+// holdLabel is the hold button's text: the state it is in, like ABS/INC.
+func holdLabel(held bool) string {
+	if held {
+		return "HOLD"
+	}
+	return "LIVE"
+}
+
+// refreshPicker chooses how often the readouts refresh. Slow devices can
+// dial it down; the choice is kept in a cookie.
+func refreshPicker(refresh int) g.Node {
+	var options []g.Node
+	for _, ms := range refreshChoices {
+		label := strconv.Itoa(ms) + " ms"
+		if ms >= 1000 {
+			label = strconv.Itoa(ms/1000) + " s"
+		}
+		options = append(options, Option(Value(strconv.Itoa(ms)), g.If(ms == refresh, Selected()), g.Text(label)))
+	}
+	return Select(
+		Name("refresh"),
+		Class("session-select"),
+		Title("Readout refresh interval"),
+		hx.Post("/api/ui/refresh"),
+		hx.Trigger("change"),
+		hx.Swap("none"),
+		g.Group(options),
+	)
+}
+
+// sessionPicker is the session dropdown and New Session button. It replaces
+// itself from /api/sessions/select whenever a session is created or switched.
+func sessionPicker(list []sessionInfo) g.Node {
+	var options []g.Node
+	for _, s := range list {
+		options = append(options, Option(Value(s.Name), g.If(s.Active, Selected()), g.Text(s.Name)))
+	}
+	return Div(
+		ID("session-group"),
+		Class("session-group"),
+		hx.Get("/api/sessions/select"),
+		hx.Trigger("sessions-changed from:body"),
+		hx.Swap("outerHTML"),
+		hx.Target("this"),
+		Select(
+			ID("session-select"),
+			Name("name"),
+			Class("session-select"),
+			hx.Post("/api/sessions/active"),
+			hx.Trigger("change"),
+			hx.Swap("none"),
+			hx.On("htmx:afterRequest", "htmx.trigger('#points-count', 'htmx:trigger')"),
+			g.Group(options),
+		),
+		Button(
+			Class("session-button"),
+			hx.Post("/api/sessions"),
+			hx.Prompt("New session name"),
+			hx.Swap("none"),
+			hx.On("htmx:afterRequest", "htmx.trigger('#points-count', 'htmx:trigger')"),
+			g.Text("New Session"),
+		),
+	)
+}
+
+func encoderFragment(data encoderData, unit, angle string, refresh int) g.Node {
 	return Div(
 		hx.Get("/api/encoder/htmx"),
-		hx.Trigger("every 200ms"),
-		hx.Vals("js:{unit: new URLSearchParams(window.location.search).get('unit') || 'mm'}"),
+		hx.Trigger(liveTrigger(refresh)),
+		hx.Vals(pageVals),
 		hx.Swap("outerHTML"),
 		hx.Target("this"),
 		ID("encoder-data"),
-		Div(Class("encoder-display"),
-			encoderDisplayXMerged(data.X, data.Xp, unit),
-			encoderDisplay("Y", data.Y, unit),
-			encoderDisplay("Z", data.Z, unit),
-		),
+		g.If(data.Hold, Div(Class("hold-badge"), g.Text("HOLD"))),
+		Div(Class("encoder-display"), g.Group(encoderCards(data, unit, angle))),
 	)
 }
 
+// encoderCards is one card per enabled axis, in chip order. X and X′ share
+// a card showing their difference when both are enabled and linear.
+func encoderCards(data encoderData, unit, angle string) []g.Node {
+	x, hasX := data.axis("X")
+	xp, hasXp := data.axis("X'")
+	merge := hasX && hasXp && x.Angle == nil && xp.Angle == nil
+	var cards []g.Node
+	for _, v := range data.Axes {
+		switch {
+		case merge && v.Label == "X":
+			cards = append(cards, encoderDisplayXMerged(v, xp, unit))
+		case merge && v.Label == "X'":
+		default:
+			cards = append(cards, axisDisplay(strings.ReplaceAll(v.Label, "'", "′"), v, unit, angle))
+		}
+	}
+	return cards
+}
+
 func formatFeetInchesFraction(mm float64) string {
 	// Handle negative values
 	isNegative := mm < 0
@@ -378,23 +877,23 @@ func formatFeetInchesFraction(mm float64) string {
 		absMM = -mm
 	}
 
-	// Convert mm to inches
-	totalInches := absMM / 25.4
-	feet := int(totalInches / 12)
-	inches := totalInches - float64(feet*12)
-
-	// Convert fractional part to nearest 1/16
-	sixteenths := int(inches * 16)
-	wholeInches := sixteenths / 16
-	fractionalSixteenths := sixteenths % 16
+	// Round the whole length to the nearest 1/den of an inch, then split it
+	// into feet, inches, and a fraction, so a fraction that rounds up to a
+	// whole inch carries into the inches, and 12" into the feet.
+	den := cfg().fractionDenominator()
+	parts := int(math.Round(absMM / 25.4 * float64(den)))
+	feet := parts / (12 * den)
+	parts %= 12 * den
+	wholeInches := parts / den
+	num := parts % den
 
-	// Build the sign prefix
+	// Build the sign prefix; a reading that rounds to zero gets none
 	sign := ""
-	if isNegative {
+	if isNegative && (feet > 0 || parts > 0) {
 		sign = "-"
 	}
 
-	if fractionalSixteenths == 0 {
+	if num == 0 {
 		if feet > 0 {
 			return fmt.Sprintf("%s%d' %d\"", sign, feet, wholeInches)
 		}
@@ -402,22 +901,8 @@ func formatFeetInchesFraction(mm float64) string {
 	}
 
 	// Simplify fraction
-	var num, den int
-	switch fractionalSixteenths {
-	case 1, 3, 5, 7, 9, 11, 13, 15:
-		// Can't simplify odd numbers
-		num = fractionalSixteenths
-		den = 16
-	case 2, 6, 10, 14:
-		num = fractionalSixteenths / 2
-		den = 8
-	case 4, 12:
-		num = fractionalSixteenths / 4
-		den = 4
-	case 8:
-		num = 1
-		den = 2
-	}
+	d := gcd(num, den)
+	num, den = num/d, den/d
 
 	if feet > 0 {
 		if wholeInches > 0 {
@@ -431,6 +916,13 @@ func formatFeetInchesFraction(mm float64) string {
 	return fmt.Sprintf("%s%d/%d\"", sign, num, den)
 }
 
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
 // distanceReadout formats distanceMM for the selected unit (primary display, unit suffix, other units line).
 func distanceReadout(distanceMM float64, selectedUnit string) (selectedDisplay string, unitLabel g.Node, otherUnitsLine string) {
 	distanceM := distanceMM / 1000.0
@@ -440,12 +932,18 @@ func distanceReadout(distanceMM float64, selectedUnit string) (selectedDisplay s
 	var selectedValue float64
 	var selectedLabel string
 	switch selectedUnit {
+	case "cm":
+		selectedValue = distanceMM / 10.0
+		selectedLabel = "cm"
 	case "m":
 		selectedValue = distanceM
 		selectedLabel = "m"
 	case "in":
 		selectedValue = distanceInches
 		selectedLabel = "in"
+	case "thou":
+		selectedValue = distanceInches * 1000.0
+		selectedLabel = "thou"
 	case "ft":
 		selectedValue = 0
 		selectedLabel = "ft"
@@ -458,8 +956,10 @@ func distanceReadout(distanceMM float64, selectedUnit string) (selectedDisplay s
 		selectedDisplay = distanceFeetInches
 	} else if selectedUnit == "m" {
 		selectedDisplay = fmt.Sprintf("%.3f", selectedValue)
-	} else if selectedUnit == "in" {
+	} else if selectedUnit == "in" || selectedUnit == "cm" {
 		selectedDisplay = fmt.Sprintf("%.3f", selectedValue)
+	} else if selectedUnit == "thou" {
+		selectedDisplay = fmt.Sprintf("%.1f", selectedValue)
 	} else {
 		selectedDisplay = fmt.Sprintf("%.2f", selectedValue)
 	}
@@ -485,17 +985,60 @@ func distanceReadout(distanceMM float64, selectedUnit string) (selectedDisplay s
 	return selectedDisplay, unitLabel, otherUnitsLine
 }
 
+// formattedReading is the card's primary readout as plain text (value and unit),
+// for thin clients that only display strings. Rotary axes use angleUnit.
+func formattedReading(v encoderValues, selectedUnit, angleUnit string) string {
+	if v.Angle != nil {
+		text, suffix, _ := angleReadout(*v.Angle, angleUnit)
+		return text + suffix
+	}
+	selectedUnit = resolveUnit(v, selectedUnit)
+	text, _, _ := distanceReadout(displayDistance(v), selectedUnit)
+	text = distanceText(v, text)
+	switch selectedUnit {
+	case "ft":
+		return text
+	case "m", "cm", "in", "thou":
+		return text + " " + selectedUnit
+	default:
+		return text + " mm"
+	}
+}
+
+// lengthText formats a length in mm as plain text in the display unit, e.g.
+// "12.35 mm" or "1' 2-3/8"". "auto" picks mm or m by size.
+func lengthText(mm float64, unit string) string {
+	if unit == "auto" {
+		unit = nextAutoUnit("", mm, 0)
+	}
+	text, _, _ := distanceReadout(mm, unit)
+	switch unit {
+	case "ft":
+		return text
+	case "m", "cm", "in", "thou":
+		return text + " " + unit
+	default:
+		return text + " mm"
+	}
+}
+
 // deltaReadout formats signed delta (X' − X) in mm for the selected unit.
 func deltaReadout(deltaMM float64, selectedUnit string) (text string, unitLabel g.Node) {
 	switch selectedUnit {
 	case "ft":
 		return formatFeetInchesFraction(deltaMM), nil
+	case "cm":
+		text = fmt.Sprintf("%+.3f", deltaMM/10.0)
+		unitLabel = Span(Class("encoder-unit-large"), g.Text(" cm"))
 	case "m":
 		text = fmt.Sprintf("%+.3f", deltaMM/1000.0)
 		unitLabel = Span(Class("encoder-unit-large"), g.Text(" m"))
 	case "in":
 		text = fmt.Sprintf("%+.3f", deltaMM/25.4)
 		unitLabel = Span(Class("encoder-unit-large"), g.Text(" in"))
+	case "thou":
+		text = fmt.Sprintf("%+.1f", deltaMM/0.0254)
+		unitLabel = Span(Class("encoder-unit-large"), g.Text(" thou"))
 	default:
 		text = fmt.Sprintf("%+.2f", deltaMM)
 		unitLabel = Span(Class("encoder-unit-large"), g.Text(" mm"))
@@ -503,11 +1046,125 @@ func deltaReadout(deltaMM float64, selectedUnit string) (text string, unitLabel
 	return text, unitLabel
 }
 
+// displayDistance clamps a runaway reading to the axis limit so the card stays readable.
+func displayDistance(v encoderValues) float64 {
+	if v.Clamped {
+		return math.Copysign(v.MaxDistance, v.Distance)
+	}
+	return v.Distance
+}
+
+// distanceClass marks a clamped reading with a warning style.
+func distanceClass(v encoderValues) string {
+	if v.Clamped {
+		return "encoder-distance encoder-clamped"
+	}
+	return "encoder-distance"
+}
+
+// distanceText prefixes a clamped reading with a warning sign.
+func distanceText(v encoderValues, text string) string {
+	if v.Clamped {
+		return "⚠ " + text
+	}
+	return text
+}
+
+// velocityReadout formats the axis's speed per second in the (resolved)
+// display unit, falling back to mm/s.
+func velocityReadout(v encoderValues, unit string) (string, string) {
+	scale, err := mmPerUnit(unit)
+	if err != nil || unit == "" {
+		scale, unit = 1, "mm"
+	}
+	return fmt.Sprintf("%.1f", v.Velocity/scale), " " + unit + "/s"
+}
+
+// errorsDetail shows the axis's failed counter reads, if any, after the rpm.
+func errorsDetail(v encoderValues) g.Node {
+	return g.If(v.Errors > 0, Span(
+		Class("encoder-errors"),
+		g.Textf(" | %d", v.Errors),
+		Span(Class("encoder-unit-small"), g.Text(" read errors")),
+	))
+}
+
+// signalMark warns that the axis's encoder signal is stale or noisy, or
+// that a suspected slip was left out of the reading.
+func signalMark(v encoderValues) g.Node {
+	var mark g.Node
+	switch v.Status {
+	case signalStale:
+		mark = Span(Class("encoder-errors"), g.Attr("title", "counter reads failing: reading is stale"), g.Text(" ⚠ stale"))
+	case signalNoisy:
+		mark = Span(Class("encoder-errors"), g.Attr("title", "count chattering or reads failing: check the wiring"), g.Text(" ⚠ noisy"))
+	}
+	return g.Group([]g.Node{
+		mark,
+		g.If(v.Suspect, Span(Class("encoder-errors"), g.Attr("title", "a jump was left out as a suspected slip: check the reading, then zero"), g.Text(" ⚠ slip"))),
+	})
+}
+
+// directionMark shows which way the axis is moving: ▲ counting up, ▼ down.
+func directionMark(v encoderValues) g.Node {
+	switch v.Direction {
+	case 1:
+		return Span(Class("encoder-direction"), g.Attr("title", "moving +"), g.Text(" ▲"))
+	case -1:
+		return Span(Class("encoder-direction"), g.Attr("title", "moving −"), g.Text(" ▼"))
+	}
+	return nil
+}
+
+// diameterMark flags an axis shown as a diameter.
+func diameterMark(v encoderValues) g.Node {
+	return g.If(v.Diameter, Span(Class("encoder-diameter"), g.Attr("title", "diameter mode"), g.Text(" Ø")))
+}
+
+// axisUnit is the axis's own unit when it has one, else the page's; it may
+// still be "auto".
+func axisUnit(v encoderValues, selectedUnit string) string {
+	if v.Unit != "" {
+		return v.Unit
+	}
+	return selectedUnit
+}
+
+// resolveUnit is the unit the axis is shown in: axisUnit, with "auto"
+// turned into the axis's current mm/m choice.
+func resolveUnit(v encoderValues, selectedUnit string) string {
+	selectedUnit = axisUnit(v, selectedUnit)
+	if selectedUnit == "auto" {
+		return v.AutoUnit
+	}
+	return selectedUnit
+}
+
+// unitCycle makes an axis label step that axis's own unit when clicked.
+func unitCycle(v encoderValues) g.Node {
+	axis := strings.ToLower(v.Label)
+	if v.Label == "X'" {
+		axis = "xp"
+	}
+	title := "click to give this axis its own unit"
+	if v.Unit != "" {
+		title = "unit fixed to " + v.Unit + "; click to change"
+	}
+	return g.Group([]g.Node{
+		hx.Post("/api/encoder/" + axis + "/unit/cycle"),
+		hx.Swap("none"),
+		g.Attr("title", title),
+		Style("cursor: pointer"),
+	})
+}
+
 func encoderDisplayXMerged(x, xp encoderValues, selectedUnit string) g.Node {
-	mainText, mainUnitLabel, otherUnitsLine := distanceReadout(x.Distance, selectedUnit)
-	deltaMM := xp.Distance - x.Distance
+	selectedUnit = resolveUnit(x, selectedUnit)
+	mainText, mainUnitLabel, otherUnitsLine := distanceReadout(displayDistance(x), selectedUnit)
+	deltaMM := displayDistance(xp) - displayDistance(x)
 	isZero := math.Abs(deltaMM) < 1e-6
 	deltaText, deltaUnitLabel := deltaReadout(deltaMM, selectedUnit)
+	xSpeed, xSpeedUnit := velocityReadout(x, selectedUnit)
 	deltaCardClass := "encoder-delta encoder-delta-zero"
 	if !isZero {
 		deltaCardClass = "encoder-delta encoder-delta-nonzero"
@@ -516,16 +1173,21 @@ func encoderDisplayXMerged(x, xp encoderValues, selectedUnit string) g.Node {
 		Class("encoder-card"),
 		Div(
 			Class("encoder-label"),
+			unitCycle(x),
 			g.Text("X"),
+			diameterMark(x),
+			directionMark(x),
+			signalMark(x),
 		),
 		Div(
-			Class("encoder-distance"),
-			g.Text(mainText),
+			Class(distanceClass(x)),
+			g.Text(distanceText(x, mainText)),
 			mainUnitLabel,
 		),
 		Div(
 			Class("encoder-label"),
 			g.Text("Δ (X′−X)"),
+			signalMark(xp),
 		),
 		Div(
 			Class(deltaCardClass),
@@ -541,6 +1203,10 @@ func encoderDisplayXMerged(x, xp encoderValues, selectedUnit string) g.Node {
 				g.Text(" | "),
 				g.Textf("%.1f", x.RPM),
 				Span(Class("encoder-unit-small"), g.Text(" rpm")),
+				g.Text(" | "),
+				g.Text(xSpeed),
+				Span(Class("encoder-unit-small"), g.Text(xSpeedUnit)),
+				errorsDetail(x),
 			),
 			Span(
 				Class("encoder-detail-item encoder-other-units"),
@@ -550,17 +1216,89 @@ func encoderDisplayXMerged(x, xp encoderValues, selectedUnit string) g.Node {
 	)
 }
 
+// axisDisplay is an axis's card: an angle for rotary axes, else a distance.
+func axisDisplay(label string, v encoderValues, unit, angle string) g.Node {
+	if v.Angle != nil {
+		return rotaryDisplay(label, v, angle)
+	}
+	return encoderDisplay(label, v, unit)
+}
+
+// hasRotary reports whether any shown axis is rotary.
+func hasRotary(data encoderData) bool {
+	return slices.ContainsFunc(data.Axes, func(v encoderValues) bool { return v.Angle != nil })
+}
+
+// angleReadout formats deg in the angle unit (deg, rad, or rev): the value,
+// its unit suffix, and the other units line.
+func angleReadout(deg float64, unit string) (text, suffix, others string) {
+	all := []struct{ unit, text string }{
+		{"deg", fmt.Sprintf("%.2f°", deg)},
+		{"rad", fmt.Sprintf("%.4f rad", deg*math.Pi/180)},
+		{"rev", fmt.Sprintf("%.4f rev", deg/360)},
+	}
+	unit = validAngleUnit(unit)
+	var rest []string
+	for _, a := range all {
+		if a.unit != unit {
+			rest = append(rest, a.text)
+			continue
+		}
+		text, suffix, _ = strings.Cut(a.text, " ")
+		if suffix != "" {
+			suffix = " " + suffix
+		}
+	}
+	return text, suffix, strings.Join(rest, " | ")
+}
+
+// rotaryDisplay is the card for a rotary axis, showing its angle.
+func rotaryDisplay(label string, values encoderValues, angle string) g.Node {
+	text, suffix, others := angleReadout(*values.Angle, angle)
+	return Div(
+		Class("encoder-card"),
+		Div(Class("encoder-label"), g.Text(label), directionMark(values), signalMark(values)),
+		Div(
+			Class("encoder-distance"),
+			g.Text(text),
+			g.If(suffix != "", Span(Class("encoder-unit-large"), g.Text(suffix))),
+		),
+		Div(
+			Class("encoder-details"),
+			Span(
+				Class("encoder-detail-item"),
+				g.Textf("%d", values.Count),
+				Span(Class("encoder-unit-small"), g.Text(" counts")),
+				g.Text(" | "),
+				g.Textf("%.1f", values.RPM),
+				Span(Class("encoder-unit-small"), g.Text(" rpm")),
+				errorsDetail(values),
+			),
+			Span(
+				Class("encoder-detail-item encoder-other-units"),
+				g.Text(others),
+			),
+		),
+	)
+}
+
 func encoderDisplay(label string, values encoderValues, selectedUnit string) g.Node {
-	selectedDisplay, unitLabel, otherUnitsLine := distanceReadout(values.Distance, selectedUnit)
+	selectedUnit = resolveUnit(values, selectedUnit)
+	selectedDisplay, unitLabel, otherUnitsLine := distanceReadout(displayDistance(values), selectedUnit)
+	speed, speedUnit := velocityReadout(values, selectedUnit)
 	return Div(
 		Class("encoder-card"),
 		Div(
 			Class("encoder-label"),
+			unitCycle(values),
 			g.Text(label),
+			diameterMark(values),
+			directionMark(values),
+			signalMark(values),
 		),
 		Div(
-			Class("encoder-distance"),
-			g.Text(selectedDisplay),
+			Class(distanceClass(values)),
+			g.Text(distanceText(values, selectedDisplay)),
 			unitLabel,
 		),
 		Div(
@@ -572,6 +1310,10 @@ func encoderDisplay(label string, values encoderValues, selectedUnit string) g.N
 				g.Text(" | "),
 				g.Textf("%.1f", values.RPM),
 				Span(Class("encoder-unit-small"), g.Text(" rpm")),
+				g.Text(" | "),
+				g.Text(speed),
+				Span(Class("encoder-unit-small"), g.Text(speedUnit)),
+				errorsDetail(values),
 			),
 			Span(
 				Class("encoder-detail-item encoder-other-units"),
@@ -0,0 +1,79 @@
+package main
+
+import "testing"
+
+// withFractionDenominator sets the live config's ft-display resolution for
+// the test.
+func withFractionDenominator(t *testing.T, den int) {
+	t.Helper()
+	saved := cfg()
+	c := *saved
+	c.FractionDenominator = den
+	liveConfig.Store(&c)
+	t.Cleanup(func() { liveConfig.Store(saved) })
+}
+
+// inches converts a length in inches to mm.
+func inches(in float64) float64 { return in * 25.4 }
+
+func TestFormatFeetInchesFractionSimplifies(t *testing.T) {
+	tests := []struct {
+		den  int
+		in   float64
+		want string
+	}{
+		{16, 8.0 / 16, `1/2"`},
+		{16, 4.0 / 16, `1/4"`},
+		{16, 3.0 / 16, `3/16"`},
+		{32, 24.0 / 32, `3/4"`},
+		{32, 2 + 4.0/32, `2-1/8"`},
+		{64, 32.0 / 64, `1/2"`},
+		{64, 48.0 / 64, `3/4"`},
+		{64, 5 + 40.0/64, `5-5/8"`},
+		{64, 5.0 / 64, `5/64"`},
+		{64, 1 + 63.0/64, `1-63/64"`},
+		{0, 8.0 / 16, `1/2"`}, // the default is sixteenths
+	}
+	for _, tt := range tests {
+		withFractionDenominator(t, tt.den)
+		if got := formatFeetInchesFraction(inches(tt.in)); got != tt.want {
+			t.Errorf("1/%d: formatFeetInchesFraction(%v\") = %s, want %s", tt.den, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatFeetInchesFractionRounding(t *testing.T) {
+	tests := []struct {
+		name string
+		den  int
+		mm   float64
+		want string
+	}{
+		{"zero", 64, 0, `0"`},
+		{"just under zero", 64, -0.001, `0"`},
+		{"negative rounding to zero", 64, -inches(1.0 / 256), `0"`},
+		{"negative fraction", 64, -inches(3.0 / 64), `-3/64"`},
+		{"negative feet", 64, -inches(14.5), `-1' 2-1/2"`},
+		{"rounds down", 64, inches(1.0 / 256), `0"`},
+		{"half a step rounds up", 64, inches(1.0 / 128), `1/64"`},
+		{"just under an inch", 64, inches(1 - 1.0/256), `1"`},
+		{"1/64 under an inch", 64, inches(1 - 1.0/64), `63/64"`},
+		{"carries into inches", 64, inches(5 - 1.0/200), `5"`},
+		{"carries into a foot", 64, inches(12 - 1.0/200), `1' 0"`},
+		{"carries into feet", 64, inches(36 - 1.0/200), `3' 0"`},
+		{"negative carries into a foot", 64, -inches(12 - 1.0/200), `-1' 0"`},
+		{"1/64 under a foot", 64, inches(12 - 1.0/64), `11-63/64"`},
+		{"11.97 in", 16, inches(11.97), `1' 0"`},
+		{"11.999 ft", 16, inches(11.999 * 12), `12' 0"`},
+		{"1/32 under an inch", 16, inches(1 - 1.0/32), `1"`},
+		{"feet and a fraction", 16, inches(12 + 1.0/2), `1' 1/2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFractionDenominator(t, tt.den)
+			if got := formatFeetInchesFraction(tt.mm); got != tt.want {
+				t.Errorf("1/%d: formatFeetInchesFraction(%v mm) = %s, want %s", tt.den, tt.mm, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// autoUnitThresholdMm is where the "auto" display unit moves between mm and m.
+const autoUnitThresholdMm = 1000.0
+
+// mmPerUnit returns how many millimeters one unit of the given display unit is.
+func mmPerUnit(unit string) (float64, error) {
+	switch unit {
+	case "", "mm":
+		return 1, nil
+	case "cm":
+		return 10, nil
+	case "m":
+		return 1000, nil
+	case "in":
+		return 25.4, nil
+	case "thou": // 0.001 in, a.k.a. mil
+		return 0.0254, nil
+	case "ft":
+		return 304.8, nil
+	default:
+		return 0, fmt.Errorf("unknown unit %q", unit)
+	}
+}
+
+// validUnit returns unit if it is a display unit (including "auto"), else mm.
+func validUnit(unit string) string {
+	if unit == "auto" {
+		return unit
+	}
+	if _, err := mmPerUnit(unit); err != nil || unit == "" {
+		return "mm"
+	}
+	return unit
+}
+
+// nextUnit is the display unit after unit in the Units button's cycle:
+// mm -> cm -> m -> in -> thou -> ft -> auto -> mm.
+func nextUnit(unit string) string {
+	switch unit {
+	case "mm":
+		return "cm"
+	case "cm":
+		return "m"
+	case "m":
+		return "in"
+	case "in":
+		return "thou"
+	case "thou":
+		return "ft"
+	case "ft":
+		return "auto"
+	}
+	return "mm"
+}
+
+// nextAxisUnit is nextUnit for one axis's own unit, with a stop at ""
+// (follow the page) after auto.
+func nextAxisUnit(unit string) string {
+	switch unit {
+	case "":
+		return "mm"
+	case "auto":
+		return ""
+	}
+	return nextUnit(unit)
+}
+
+// degPerAngleUnit returns how many degrees one unit of the given angle unit
+// (for rotary axes) is.
+func degPerAngleUnit(unit string) (float64, error) {
+	switch unit {
+	case "", "deg":
+		return 1, nil
+	case "rad":
+		return 180 / math.Pi, nil
+	case "rev":
+		return 360, nil
+	default:
+		return 0, fmt.Errorf("unknown angle unit %q", unit)
+	}
+}
+
+// validAngleUnit returns unit if it is an angle unit, else deg.
+func validAngleUnit(unit string) string {
+	if _, err := degPerAngleUnit(unit); err != nil || unit == "" {
+		return "deg"
+	}
+	return unit
+}
+
+// nextAutoUnit picks mm or m for the "auto" display unit. The current choice is
+// sticky: it only changes once |distanceMM| is more than band past the
+// threshold, so a reading hovering at 1 m doesn't flicker between units.
+func nextAutoUnit(current string, distanceMM, band float64) string {
+	d := math.Abs(distanceMM)
+	switch {
+	case current == "m" && d < autoUnitThresholdMm-band:
+		return "mm"
+	case current != "m" && d >= autoUnitThresholdMm+band:
+		return "m"
+	case current == "":
+		if d >= autoUnitThresholdMm {
+			return "m"
+		}
+		return "mm"
+	}
+	return current
+}